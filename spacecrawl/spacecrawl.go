@@ -1,8 +1,14 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
+	"fmt"
+	"math"
 	"net/http"
+	"runtime"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -10,7 +16,6 @@ import (
 
 	"github.com/anyweez/edpaths/structs" // local
 
-	"github.com/fighterlyt/permutation"
 	"github.com/gin-gonic/contrib/static"
 	"github.com/gin-gonic/gin"
 	"github.com/itsjamie/gin-cors"
@@ -19,31 +24,113 @@ import (
 type RouteResponse struct {
 	Status int
 	Route  *structs.SpaceRoute
+	Error  string
+	Code   string // identifies the kind of error; see structs.ErrorCode
+
+	Unreachable structs.SystemID // waypoint that couldn't be reached, if any
+
+	// Every route on the Pareto front, when they're asked for with `mode=pareto`.
+	Pareto []*structs.ParetoRoute `json:",omitempty"`
+	// The cheapest few routes, best first, when they're asked for with `alternatives`.
+	Alternatives []*structs.AlternativeRoute `json:",omitempty"`
+}
+
+type SystemResponse struct {
+	Status   int
+	Stations []*structs.SpaceStation `json:",omitempty"`
+	Bodies   []*structs.SpaceBody    `json:",omitempty"`
+	Error    string
+	Code     string
+}
+
+type NearestResponse struct {
+	Status  int
+	Systems []*structs.NearbySystem
+	Error   string
+	Code    string
 }
 
 type ServerConfig struct {
 	ReleaseMode   bool
 	SystemsTarget string
 	CellSize      int
+	TourBudget    time.Duration
+	RouteCache    int
+	RouteTimeout  time.Duration
+	MaxExpansions int
+	Index         string
 }
 
 var config ServerConfig
 
-func init() {
+// Most systems that /nearest will return at once.
+const maxNearest = 100
+
+// Most alternative routes that can be requested at once.
+const maxAlternatives = 10
+
+// Most waypoints (including the start and end) that a route can visit. Ordering them searches
+// every pair, so this keeps a single request from tying the server up. Tours with more than
+// structs.HeldKarpLimit stops are ordered with heuristics, within the tour budget.
+const maxWaypoints = 40
+
+// Most routes that are searched at the same time while ordering waypoints or counting the jumps
+// to nearby systems.
+var legWorkers = runtime.NumCPU()
+
+// Code for errors in the params of a request, alongside the ones from structs.ErrorCode.
+const codeBadRequest = "bad_request"
+
+/**
+ * Reads the command line flags into the config.
+ */
+func parseFlags() {
 	_releaseMode := flag.Bool("release", false, "execute in release mode")
 	_systemsTarget := flag.String("systems", "systems", "set of systems to read")
 	_cellSize := flag.Int("cell", 1000, "size of cell, in light years")
+	_tourBudget := flag.Duration("tour-budget", 2*time.Second, "time allowed for ordering waypoints")
+	_routeCache := flag.Int("route-cache", 0, "number of legs to cache across requests (0 to disable)")
+	_routeTimeout := flag.Duration("route-timeout", 30*time.Second, "time allowed for finding a route")
+	_maxExpansions := flag.Int("max-expansions", 0, "most systems to expand when finding each leg (0 for no limit)")
+	_index := flag.String("index", "grid", "spatial index to use for neighbour lookups (grid or kdtree)")
 
 	flag.Parse()
 
 	config.ReleaseMode = *_releaseMode
 	config.SystemsTarget = *_systemsTarget
 	config.CellSize = *_cellSize
+	config.TourBudget = *_tourBudget
+	config.RouteCache = *_routeCache
+	config.RouteTimeout = *_routeTimeout
+	config.MaxExpansions = *_maxExpansions
+	config.Index = *_index
 }
 
 func main() {
+	parseFlags()
+
 	db := structs.Connect(config.SystemsTarget)
-	graph := structs.InitGraph(float64(config.CellSize)).Load(db)
+	graph := structs.InitGraph(float64(config.CellSize))
+	if config.Index == "kdtree" {
+		graph.UseIndex(structs.NewKDTree())
+	}
+	graph.Load(db)
+
+	var shared *structs.RouteCache
+	if config.RouteCache > 0 {
+		shared = structs.NewRouteCache(graph, config.RouteCache)
+	}
+
+	router := newRouter(db, graph, shared)
+	router.Use(static.Serve("/", static.LocalFile("./web/build", true)))
+	router.Run()
+}
+
+/**
+ * Sets up all of the API's routes. Legs are shared between requests through `shared` if it
+ * isn't nil.
+ */
+func newRouter(db *structs.SpaceDB, graph *structs.SpaceGraph, shared *structs.RouteCache) *gin.Engine {
 	terms := structs.NewAutocomplete(db)
 
 	if config.ReleaseMode {
@@ -67,54 +154,109 @@ func main() {
 	 * complex and should likely be refactored in the future.
 	 *
 	 * Currently it's considering three query params: `from` (id to start from), `to` (id to
-	 * end on), and `visit`, a comma-delimited list of id's to visit in the middle. We find
-	 * all permutations and solve each on its own goroutine, merging each of the subroutes
-	 * together as we go. At the end we compare all of the combined routes to determine
-	 * which is the shortest and return that.
+	 * end on), and `visit`, a comma-delimited list of id's to visit in the middle (up to
+	 * maxWaypoints in all). The ship can be chosen with `ship` (see parseShip for details);
+	 * without one, jumps are limited to `jump` light years (18 by default) and fuel is only
+	 * tracked if `tank` (tank size in tons) and `jumpfuel` (tons used per jump) are provided.
+	 * Ships are assumed to start with a full tank. Ships can always refuel by scooping, but
+	 * only dock to refuel at stations with a landing pad that's big enough: `pad` (S, M or L)
+	 * overrides the ship's own pad size.
+	 * Passing `supercharge=true` plans routes along the neutron highway, using neutron stars
+	 * and white dwarfs to boost jump range wherever possible. Routes are found
+	 * with A* unless `mode=greedy` is passed, and `weight` can be used to trade accuracy for
+	 * speed (see structs.RoutingConstraints). Routes can be kept out of systems with `avoid`
+	 * (a comma-delimited list of id's) and out of spherical regions with `avoidregion` (given
+	 * as `x,y,z,radius`, and can be repeated). If a waypoint can only be reached by going
+	 * through something that's avoided, the response has the code `avoided` and says which
+	 * waypoint it was in `Unreachable`.
+	 *
+	 * Systems that need a permit are never routed through unless the permit is listed in
+	 * `permits` (a comma-delimited list of id's), or `permits=all` is passed.
+	 *
+	 * Kinds of systems (any of the filters in structs.Filters, like `anarchy` or `war`) can be
+	 * avoided completely with `avoidtype`, or just discouraged with `penalty`, which takes a
+	 * comma-delimited list of `filter:penalty` pairs. Penalties are added to the cost of each
+	 * jump into a matching system, in LY (or jumps when supercharging).
+	 *
+	 * Stops whose arrival star is a neutron star, white dwarf or black hole say so in `Hazard`.
+	 * Passing `avoidhazards=true` keeps routes out of those systems altogether, apart from the
+	 * waypoints themselves (supercharged routes still use neutron stars and white dwarfs).
+	 *
+	 * Waypoints are visited in whichever order is cheapest. We find routes between every pair
+	 * of waypoints, use them to decide on the order (see structs.Tour), and then merge the legs
+	 * together in that order. Each leg is only solved once per request, and legs are also kept
+	 * in a server-wide cache if `-route-cache` is set. The whole request needs to finish within
+	 * `-route-timeout`, and searches stop early if the client goes away. Each stop lists the
+	 * stations in its system.
+	 *
+	 * Any waypoint can be a station instead of a system by putting an `s` in front of its id
+	 * (like `to=s128`). Supercruising out to a station isn't free, so the time it takes is
+	 * estimated from its distance to the arrival star, reported with the stop and added to the
+	 * cost of the leg (as however far the ship could have jumped in the same time). Stops where
+	 * the ship docks to refuel report the closest suitable station in the same way.
+	 *
+	 * Every route comes with an estimate of how long it takes to fly in `Duration` (seconds).
+	 * Passing `optimize=time` finds the quickest route instead of the shortest one, and the
+	 * cost is then measured in seconds too. Time spent scooping fuel is counted if the rate the
+	 * ship scoops at is given in `scooprate` (tons per second).
+	 *
+	 * Passing `mode=pareto` returns every route that isn't beaten on all of jumps, distance and
+	 * unscoopable stops by another one in `Pareto`, each with its criteria (see
+	 * structs.FindPareto), and the one with the fewest jumps in `Route`. This needs a `from`
+	 * and a `to`, and doesn't support `visit`. Fuel isn't tracked, so it can't be used with
+	 * `tank` or `ship` either.
+	 *
+	 * Passing `alternatives=K` returns up to K loopless routes in `Alternatives`, cheapest first
+	 * (see structs.FindAlternatives), with how much more each one costs than the best route and
+	 * which systems it goes through that the best one doesn't. The best route is also in `Route`.
+	 * This also needs a `from` and a `to` and doesn't support `visit`, but unlike `mode=pareto`
+	 * fuel is tracked, so `tank` and `ship` work. K can be up to 10.
 	 */
 	router.GET("/route", func(ctx *gin.Context) {
 		if ctx.Query("from") == "" && ctx.Query("to") == "" {
-			ctx.JSON(http.StatusBadRequest, RouteResponse{
-				Status: http.StatusBadRequest,
-			})
-
+			badRoute(ctx, "a from or a to is required")
 			return
 		}
 
-		var startID structs.SystemID // where to start
-		var endID structs.SystemID   // where to end
-		var visit []structs.SystemID // places to visit along the way
+		start := ctx.Query("from") != "" // whether the route has a fixed start
+		end := ctx.Query("to") != ""     // whether the route has a fixed end
 
-		if len(ctx.Query("from")) > 0 {
-			id, _ := strconv.Atoi(ctx.Query("from"))
-			startID = structs.SystemID(id)
+		var visit []string // places to visit along the way
+		if len(ctx.Query("visit")) > 0 {
+			visit = strings.Split(ctx.Query("visit"), ",")
 		}
 
-		if len(ctx.Query("to")) > 0 {
-			id, _ := strconv.Atoi(ctx.Query("to"))
-			endID = structs.SystemID(id)
+		constraints, err := parseConstraints(ctx)
+		if err != nil {
+			badRoute(ctx, err.Error())
+			return
 		}
 
-		if len(ctx.Query("visit")) > 0 {
-			for _, rawID := range strings.Split(ctx.Query("visit"), ",") {
-				id, _ := strconv.Atoi(rawID)
-				visit = append(visit, structs.SystemID(id))
-			}
+		// Look up all of the systems (and stations) we need to visit.
+		refs := waypointRefs(ctx.Query("from"), ctx.Query("to"), visit)
+		if len(refs) > maxWaypoints {
+			badRoute(ctx, "routes can have at most "+strconv.Itoa(maxWaypoints)+" waypoints")
+			return
 		}
 
-		// if we don't have any points, return error
-		if len(visit) == 0 && startID == 0 && endID == 0 {
-			ctx.JSON(http.StatusOK, RouteResponse{
-				Status: http.StatusNotFound,
-				Route:  nil,
-			})
-			return
+		waypoints := make([]*structs.SpaceSystem, len(refs))
+		stations := make([]*structs.SpaceStation, len(refs))
+
+		for i, ref := range refs {
+			if waypoints[i], stations[i], err = findWaypoint(db, graph, ref); errors.Is(err, errBadWaypoint) {
+				badRoute(ctx, err.Error())
+				return
+			} else if err != nil {
+				routeError(ctx, err)
+				return
+			}
 		}
 
-		// if we've only got an ending point, return it
-		if len(visit) == 0 && endID == 0 {
-			orig := graph.Get(startID).AsStop()
+		// if we've only got a starting point, return it
+		if len(visit) == 0 && !end {
+			orig := waypoints[0].AsStop()
 			orig.RequestedStop = true
+			orig.Station = stations[0]
 
 			ctx.JSON(http.StatusOK, RouteResponse{
 				Status: http.StatusOK,
@@ -126,9 +268,11 @@ func main() {
 		}
 
 		// if we've only got an ending point, return it
-		if len(visit) == 0 && startID == 0 {
-			dest := graph.Get(endID).AsStop()
+		if len(visit) == 0 && !start {
+			dest := waypoints[0].AsStop()
 			dest.RequestedStop = true
+			dest.Station = stations[0]
+			dest.SupercruiseTime = stations[0].SupercruiseTime()
 
 			ctx.JSON(http.StatusOK, RouteResponse{
 				Status: http.StatusOK,
@@ -139,93 +283,253 @@ func main() {
 			return
 		}
 
-		routes := make(chan *structs.SpaceRoute, 10)
-		var track sync.WaitGroup
-
-		// Check all variants in parallel. We're looking for the combination that leads to the shortest overall
-		// distance traveled.
-		for _, variant := range getVariants(startID, endID, visit) {
-			// Find routes for all legs of the journey and concat them together
-			go func() {
-				now := graph.Get(variant[0])
-				next := graph.Get(variant[1])
-				current := 1
-
-				// Initial route; will be the base for all Merge() calls on this vairant.
-				route := structs.SpaceRoute{
-					Origin:      now.AsStop(),
-					Destination: nil,
-					Stops:       make([]*structs.SpaceStop, 0, len(visit)+2),
-					Distance:    0,
-					Checks:      0,
+		search, cancel := context.WithTimeout(ctx.Request.Context(), config.RouteTimeout)
+		defer cancel()
+
+		if ctx.Query("mode") == structs.SearchPareto {
+			if !start || !end || len(visit) > 0 {
+				badRoute(ctx, "pareto routes need a from and a to, and can't visit anything else")
+				return
+			}
+
+			// Pareto routes don't plan where to refuel, so they'd happily run the tank dry.
+			if constraints.TracksFuel() {
+				badRoute(ctx, "pareto routes don't track fuel, so they can't be used with a tank or a ship")
+				return
+			}
+
+			front, err := graph.FindPareto(search, waypoints[0], waypoints[1], &constraints)
+			if err != nil {
+				routeError(ctx, graph.BlameRestrictions(search, err, &constraints))
+				return
+			}
+
+			for _, option := range front {
+				arriveAtStations(option.Route, stations[0], stations[1], &constraints)
+
+				for _, stop := range option.Route.Stops {
+					stop.Stations = db.StationsIn(stop.System.ID)
+				}
+			}
+
+			ctx.JSON(http.StatusOK, RouteResponse{
+				Status: http.StatusOK,
+				Route:  front[0].Route,
+				Pareto: front,
+			})
+			return
+		}
+
+		if len(ctx.Query("alternatives")) > 0 {
+			k, _ := strconv.Atoi(ctx.Query("alternatives"))
+			if !start || !end || len(visit) > 0 || k <= 0 || k > maxAlternatives {
+				badRoute(ctx, "alternatives need a from and a to, can't visit anything else, and must be between 1 and "+strconv.Itoa(maxAlternatives))
+				return
+			}
+
+			alternatives, err := graph.FindAlternatives(search, waypoints[0], waypoints[1], &constraints, k)
+			if err != nil {
+				routeError(ctx, graph.BlameRestrictions(search, err, &constraints))
+				return
+			}
+
+			for _, alternative := range alternatives {
+				arriveAtStations(alternative.Route, stations[0], stations[1], &constraints)
+
+				for _, stop := range alternative.Route.Stops {
+					stop.Stations = db.StationsIn(stop.System.ID)
 				}
+				dockForFuel(alternative.Route, &constraints)
+			}
+
+			ctx.JSON(http.StatusOK, RouteResponse{
+				Status:       http.StatusOK,
+				Route:        alternatives[0].Route,
+				Alternatives: alternatives,
+			})
+			return
+		}
+
+		// Work out the cheapest order to visit everything in, then stitch the legs together.
+		legs := structs.NewLegCache(graph, shared)
+		order := orderWaypoints(search, legs, waypoints, stations, start, end, &constraints)
+		route, err := buildRoute(search, legs, waypoints, stations, order, &constraints)
 
-				// Append the starting point (since it won't be copied from the first leg via Merge())
-				route.Stops = append(route.Stops, &structs.SpaceStop{
-					System:        now,
-					RequestedStop: true,
-				})
+		if err != nil {
+			routeError(ctx, graph.BlameRestrictions(search, err, &constraints))
+			return
+		}
 
-				for current < len(variant) {
-					upcoming := graph.FindPath(now, next, &structs.RoutingConstraints{MaxJump: 18.0, MaxHops: 200})
+		for _, stop := range route.Stops {
+			stop.Stations = db.StationsIn(stop.System.ID)
+		}
+		dockForFuel(route, &constraints)
 
-					if upcoming != nil {
-						// Mark beginning and end as requested stops.
-						upcoming.Stops[0].RequestedStop = true
-						upcoming.Stops[len(upcoming.Stops)-1].RequestedStop = true
+		ctx.JSON(http.StatusOK, RouteResponse{
+			Status: http.StatusOK,
+			Route:  route,
+		})
+	})
 
-						route.Merge(upcoming)
-					}
+	/**
+	 * Finds the systems closest to an origin that match a filter. The origin is either a system
+	 * (`from`) or a set of coordinates (`x`, `y` and `z`), and `filter` is a comma-delimited list
+	 * of filters from structs.Filters that systems have to match all of. Up to `limit` systems
+	 * are returned (1 by default), and `within` limits how far away they can be in LY.
+	 *
+	 * Passing `jumps=true` also finds a route to each match and reports how many jumps it takes
+	 * (-1 if there's no route), using the same routing params as /route. This only works with
+	 * a system as the origin.
+	 */
+	router.GET("/nearest", func(ctx *gin.Context) {
+		var origin *structs.SpaceSystem
+		var err error
 
-					// move on to the next leg
-					current++
+		if len(ctx.Query("from")) > 0 {
+			id, _ := strconv.Atoi(ctx.Query("from"))
+			if origin = graph.Get(structs.SystemID(id)); origin == nil {
+				nearestError(ctx, http.StatusNotFound, structs.ErrUnknownSystem)
+				return
+			}
+		} else if len(ctx.Query("x")) > 0 && len(ctx.Query("y")) > 0 && len(ctx.Query("z")) > 0 {
+			origin = &structs.SpaceSystem{}
 
-					if current < len(variant) {
-						now = next
-						next = graph.Get(variant[current])
-					}
+			coords := [3]*float64{&origin.X, &origin.Y, &origin.Z}
+			for i, name := range [3]string{"x", "y", "z"} {
+				if *coords[i], err = parseNumber(name, ctx.Query(name)); err != nil {
+					nearestError(ctx, http.StatusBadRequest, err)
+					return
 				}
+			}
+		} else {
+			nearestError(ctx, http.StatusBadRequest, errors.New("an origin system or coordinates are required"))
+			return
+		}
 
-				routes <- &route
-				track.Done()
-			}()
+		filter, err := structs.ParseFilter(ctx.Query("filter"))
+		if err != nil {
+			nearestError(ctx, http.StatusBadRequest, err)
+			return
+		}
 
-			track.Add(2) // one for computation, one for comparison
+		limit := 1
+		if len(ctx.Query("limit")) > 0 {
+			limit, _ = strconv.Atoi(ctx.Query("limit"))
 		}
 
-		// Close the routes channel when all results are in.
-		go func() {
-			track.Wait()
-			close(routes)
-		}()
+		if limit <= 0 || limit > maxNearest {
+			nearestError(ctx, http.StatusBadRequest, errors.New("limit must be between 1 and "+strconv.Itoa(maxNearest)))
+			return
+		}
+
+		within := 0.0
+		if len(ctx.Query("within")) > 0 {
+			if within, err = parseNumber("within", ctx.Query("within")); err != nil {
+				nearestError(ctx, http.StatusBadRequest, err)
+				return
+			}
+		}
+
+		matches := graph.FindNearest(origin, filter, limit, within)
+
+		if ctx.Query("jumps") == "true" {
+			if graph.Get(origin.ID) != origin {
+				nearestError(ctx, http.StatusBadRequest, errors.New("jumps can only be counted from a system"))
+				return
+			}
+
+			constraints, err := parseConstraints(ctx)
+			if err != nil {
+				nearestError(ctx, http.StatusBadRequest, err)
+				return
+			}
+
+			search, cancel := context.WithTimeout(ctx.Request.Context(), config.RouteTimeout)
+			defer cancel()
+
+			countJumps(search, structs.NewLegCache(graph, shared), origin, matches, &constraints)
 
-		var route *structs.SpaceRoute
-		for result := range routes {
-			if route == nil {
-				route = result
-			} else if result.Distance < route.Distance {
-				route = result
+			if err := search.Err(); err != nil {
+				nearestError(ctx, http.StatusGatewayTimeout, err)
+				return
 			}
+		}
+
+		ctx.JSON(http.StatusOK, NearestResponse{
+			Status:  http.StatusOK,
+			Systems: matches,
+		})
+	})
+
+	/**
+	 * Lists the stations in a system.
+	 */
+	router.GET("/system/:id/stations", func(ctx *gin.Context) {
+		id, _ := strconv.Atoi(ctx.Param("id"))
+		if graph.Get(structs.SystemID(id)) == nil {
+			ctx.JSON(http.StatusNotFound, SystemResponse{
+				Status: http.StatusNotFound,
+				Error:  structs.ErrUnknownSystem.Error(),
+				Code:   structs.ErrUnknownSystem.Code,
+			})
 
-			track.Done()
+			return
 		}
 
-		// Wait until all variants have been tested and compared against each other. Once that's
-		// done `route` should be the shortest route to reach all provided points.
-		track.Wait()
+		ctx.JSON(http.StatusOK, SystemResponse{
+			Status:   http.StatusOK,
+			Stations: db.StationsIn(structs.SystemID(id)),
+		})
+	})
 
-		// Choose the best of the available routes
-		if route == nil {
-			ctx.JSON(http.StatusOK, RouteResponse{
+	/**
+	 * Lists the bodies in a system, closest to the arrival star first. Passing `landable=true`
+	 * or `terraformable=true` only lists bodies that are.
+	 */
+	router.GET("/system/:id/bodies", func(ctx *gin.Context) {
+		id, _ := strconv.Atoi(ctx.Param("id"))
+		if graph.Get(structs.SystemID(id)) == nil {
+			ctx.JSON(http.StatusNotFound, SystemResponse{
 				Status: http.StatusNotFound,
-				Route:  nil,
+				Error:  structs.ErrUnknownSystem.Error(),
+				Code:   structs.ErrUnknownSystem.Code,
 			})
-		} else {
-			ctx.JSON(http.StatusOK, RouteResponse{
-				Status: http.StatusOK,
-				Route:  route,
+
+			return
+		}
+
+		all, err := db.BodiesIn(structs.SystemID(id))
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, SystemResponse{
+				Status: http.StatusInternalServerError,
+				Error:  err.Error(),
+				Code:   structs.CodeInternal,
 			})
+
+			return
+		}
+
+		var bodies []*structs.SpaceBody
+		for _, body := range all {
+			if ctx.Query("landable") == "true" && !body.IsLandable {
+				continue
+			}
+
+			if ctx.Query("terraformable") == "true" && !body.IsTerraformable() {
+				continue
+			}
+
+			bodies = append(bodies, body)
 		}
+
+		sort.Slice(bodies, func(i, j int) bool {
+			return bodies[i].DistanceToArrival < bodies[j].DistanceToArrival
+		})
+
+		ctx.JSON(http.StatusOK, SystemResponse{
+			Status: http.StatusOK,
+			Bodies: bodies,
+		})
 	})
 
 	/**
@@ -238,36 +542,569 @@ func main() {
 		ctx.JSON(http.StatusOK, terms.GetAll(query, 5))
 	})
 
-	router.Use(static.Serve("/", static.LocalFile("./web/build", true)))
-	router.Run()
+	return router
 }
 
-// TODO: test this
-func getVariants(start structs.SystemID, end structs.SystemID, visit []structs.SystemID) [][]structs.SystemID {
-	var variants [][]structs.SystemID
+/**
+ * Returns the cheapest order to visit the waypoints in, as indexes into `waypoints`. If `start`
+ * or `end` is set then the first or last waypoint stays where it is. Legs are found by a pool
+ * of legWorkers goroutines, and assume that the ship starts each one with a full tank. Legs that
+ * end at a station also include the supercruise out to it.
+ */
+func orderWaypoints(ctx context.Context, legs *structs.LegCache, waypoints []*structs.SpaceSystem, stations []*structs.SpaceStation, start bool, end bool, cons *structs.RoutingConstraints) []int {
+	tour := structs.Tour{
+		Costs:      make([][]float64, len(waypoints)),
+		FixedStart: start,
+		FixedEnd:   end,
+	}
+
+	// Nothing to decide if there are only two waypoints and one of them is fixed in place.
+	if len(waypoints) <= 2 && (start || end) {
+		order := make([]int, len(waypoints))
+		for i := range order {
+			order[i] = i
+		}
+
+		return order
+	}
+
+	// Legs are searched by a fixed number of workers, however many waypoints there are.
+	pairs := make(chan [2]int)
+	var track sync.WaitGroup
+
+	for w := 0; w < legWorkers; w++ {
+		track.Add(1)
+		go func() {
+			defer track.Done()
+
+			for pair := range pairs {
+				i, j := pair[0], pair[1]
+
+				leg := *cons
+				leg.StartFuel = nil
+
+				if route, err := legs.FindPath(ctx, waypoints[i], waypoints[j], &leg); err == nil {
+					tour.Costs[i][j] = route.Cost + cons.TimeCost(stations[j].SupercruiseTime())
+				}
+			}
+		}()
+	}
 
-	// If no visits are provided, start and end are required.
-	if len(visit) == 0 {
-		variants = append(variants, []structs.SystemID{start, end})
-		return variants
+	for i := range waypoints {
+		tour.Costs[i] = make([]float64, len(waypoints))
 	}
 
-	gen, _ := permutation.NewPerm(visit, nil)
+	// Legs that haven't been handed out by the time the request runs out of time are left as
+	// impossible; the route can't be finished in time anyway.
+	for i := range waypoints {
+		for j := range waypoints {
+			tour.Costs[i][j] = math.Inf(1)
 
-	for gen.Left() > 0 {
-		next, _ := gen.Next()
-		nextIds := next.([]structs.SystemID)
+			// No need to find legs that lead back to the start or away from the end.
+			if i == j || (start && j == 0) || (end && i == len(waypoints)-1) || ctx.Err() != nil {
+				continue
+			}
 
-		if start != 0 {
-			nextIds = append([]structs.SystemID{start}, nextIds...)
+			select {
+			case pairs <- [2]int{i, j}:
+			case <-ctx.Done():
+			}
 		}
+	}
+
+	close(pairs)
+	track.Wait()
+
+	// Ordering can't take any longer than the request has left.
+	budget := config.TourBudget
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < budget {
+		budget = time.Until(deadline)
+	}
+
+	return tour.OrderStops(budget)
+}
+
+/**
+ * Finds routes for all legs of the journey, visiting the waypoints in the specified order, and
+ * concats them together. Fuel is carried over from one leg to the next. If any leg can't be
+ * completed then the whole route fails. Supercruising to stations is added to the cost.
+ */
+func buildRoute(ctx context.Context, legs *structs.LegCache, waypoints []*structs.SpaceSystem, stations []*structs.SpaceStation, order []int, cons *structs.RoutingConstraints) (*structs.SpaceRoute, error) {
+	leg := *cons
+	now := waypoints[order[0]]
+
+	// Initial route; will be the base for all Merge() calls.
+	route := &structs.SpaceRoute{
+		Origin:      now.AsStop(),
+		Destination: nil,
+		Stops:       make([]*structs.SpaceStop, 0, len(waypoints)),
+		Distance:    0,
+		Mode:        cons.SearchMode(),
+		Checks:      0,
+	}
+
+	// Append the starting point (since it won't be copied from the first leg via Merge())
+	start := now.AsStop()
+	start.RequestedStop = true
+	start.Station = stations[order[0]]
+	route.Stops = append(route.Stops, start)
+
+	for _, i := range order[1:] {
+		next := waypoints[i]
+		upcoming, err := legs.FindPath(ctx, now, next, &leg)
+		if err != nil {
+			return nil, err
+		}
+
+		// Mark beginning and end as requested stops.
+		upcoming.Stops[0].RequestedStop = true
+		upcoming.Stops[len(upcoming.Stops)-1].RequestedStop = true
+
+		if station := stations[i]; station != nil {
+			arrival := upcoming.Stops[len(upcoming.Stops)-1]
+			arrival.Station = station
+			arrival.SupercruiseTime = station.SupercruiseTime()
+			upcoming.Cost += cons.TimeCost(arrival.SupercruiseTime)
+			upcoming.Duration += arrival.SupercruiseTime
+		}
+
+		// The first stop of this leg is the last stop of the previous one, so it
+		// needs to carry over any refuelling that happens there.
+		route.Stops[len(route.Stops)-1].Refuel = upcoming.Stops[0].Refuel
+		route.Stops[len(route.Stops)-1].FuelRemaining = upcoming.Stops[0].FuelRemaining
+
+		route.Merge(upcoming)
+
+		// Pick up the next leg with whatever fuel is left in the tank.
+		remaining := upcoming.Stops[len(upcoming.Stops)-1].FuelRemaining
+		leg.StartFuel = &remaining
+
+		// move on to the next leg
+		now = next
+	}
+
+	return route, nil
+}
+
+/**
+ * Marks the stations that a single leg route starts and ends at, if either end is a station, and
+ * adds the supercruise out to the destination station to the route.
+ */
+func arriveAtStations(route *structs.SpaceRoute, start *structs.SpaceStation, end *structs.SpaceStation, cons *structs.RoutingConstraints) {
+	route.Stops[0].RequestedStop = true
+	route.Stops[0].Station = start
+
+	arrival := route.Stops[len(route.Stops)-1]
+	arrival.RequestedStop = true
+
+	if end != nil {
+		arrival.Station = end
+		arrival.SupercruiseTime = end.SupercruiseTime()
+		route.Cost += cons.TimeCost(arrival.SupercruiseTime)
+		route.Duration += arrival.SupercruiseTime
+	}
+}
+
+/**
+ * Picks a station for each stop where the ship needs to dock to refuel. That's the one that's the
+ * shortest supercruise away, which is the one the search planned on when it worked out the cost.
+ */
+func dockForFuel(route *structs.SpaceRoute, cons *structs.RoutingConstraints) {
+	for _, stop := range route.Stops {
+		if !stop.Refuel || stop.System.ContainsScoopableStar || stop.Station != nil {
+			continue
+		}
+
+		// The search already counted the time it takes to get to this station.
+		stop.Station = stop.System.RefuelStation(cons.LandingPad())
+		stop.SupercruiseTime = stop.Station.SupercruiseTime()
+	}
+}
+
+/**
+ * Returns the references to all waypoints in order, with the start (if there is one) first and
+ * the end (if there is one) last.
+ */
+func waypointRefs(start string, end string, visit []string) []string {
+	var refs []string
+
+	if start != "" {
+		refs = append(refs, start)
+	}
+
+	refs = append(refs, visit...)
+
+	if end != "" {
+		refs = append(refs, end)
+	}
+
+	return refs
+}
+
+// Returned by findWaypoint when a waypoint isn't an id at all, which is the caller's fault.
+var errBadWaypoint = errors.New("waypoints should be system ids, or station ids with an s in front")
+
+/**
+ * Looks up a waypoint. Waypoints are usually system id's, but can also be station id's with an
+ * `s` in front of them (like `s128`), in which case the station is returned along with its system.
+ */
+func findWaypoint(db *structs.SpaceDB, graph *structs.SpaceGraph, ref string) (*structs.SpaceSystem, *structs.SpaceStation, error) {
+	if strings.HasPrefix(ref, "s") {
+		id, err := strconv.Atoi(strings.TrimPrefix(ref, "s"))
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %s", errBadWaypoint, ref)
+		}
+
+		station := db.Station(structs.SystemID(id))
+		if station == nil || graph.Get(station.SystemID) == nil {
+			return nil, nil, &structs.LegError{Err: structs.ErrUnknownStation}
+		}
+
+		return graph.Get(station.SystemID), station, nil
+	}
+
+	id, err := strconv.Atoi(ref)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %s", errBadWaypoint, ref)
+	}
+
+	system := graph.Get(structs.SystemID(id))
+	if system == nil {
+		return nil, nil, &structs.LegError{From: structs.SystemID(id), To: structs.SystemID(id), Err: structs.ErrUnknownSystem}
+	}
+
+	return system, nil, nil
+}
+
+/**
+ * Responds with the error that prevented a route from being found. The HTTP status depends on
+ * what kind of error it was.
+ */
+func routeError(ctx *gin.Context, err error) {
+	status := errorStatus(err)
+	response := RouteResponse{
+		Status: status,
+		Error:  err.Error(),
+		Code:   structs.ErrorCode(err),
+	}
+
+	var leg *structs.LegError
+	if errors.As(err, &leg) {
+		response.Unreachable = leg.To
+	}
+
+	ctx.JSON(status, response)
+}
+
+/**
+ * Responds to a /route request whose params don't make sense.
+ */
+func badRoute(ctx *gin.Context, message string) {
+	ctx.JSON(http.StatusBadRequest, RouteResponse{
+		Status: http.StatusBadRequest,
+		Error:  message,
+		Code:   codeBadRequest,
+	})
+}
+
+/**
+ * Returns the HTTP status that best describes an error returned from the routing functions.
+ */
+func errorStatus(err error) int {
+	status := http.StatusInternalServerError
+
+	switch structs.ErrorCode(err) {
+	case structs.ErrUnknownSystem.Code, structs.ErrUnknownStation.Code, structs.ErrUnreachable.Code,
+		structs.ErrOutOfFuel.Code, structs.ErrHopLimit.Code, structs.ErrAvoided.Code, structs.ErrFiltered.Code,
+		structs.ErrPermitRequired.Code:
+		status = http.StatusNotFound
+	case structs.ErrOutOfBounds.Code:
+		status = http.StatusBadRequest
+	case structs.ErrBudgetExhausted.Code:
+		status = http.StatusServiceUnavailable
+	case structs.CodeTimedOut, structs.CodeCancelled:
+		status = http.StatusGatewayTimeout
+	}
+
+	return status
+}
+
+func nearestError(ctx *gin.Context, status int, err error) {
+	code := structs.ErrorCode(err)
+	if status == http.StatusBadRequest {
+		code = codeBadRequest
+	}
+
+	ctx.JSON(status, NearestResponse{
+		Status: status,
+		Error:  err.Error(),
+		Code:   code,
+	})
+}
+
+/**
+ * Finds a route to each of the matches and records how many jumps it takes, or -1 if there isn't
+ * a route. Routes are found by a pool of legWorkers goroutines, like the legs in orderWaypoints.
+ */
+func countJumps(ctx context.Context, legs *structs.LegCache, origin *structs.SpaceSystem, matches []*structs.NearbySystem, cons *structs.RoutingConstraints) {
+	queue := make(chan *structs.NearbySystem)
+	var track sync.WaitGroup
+
+	for w := 0; w < legWorkers; w++ {
+		track.Add(1)
+		go func() {
+			defer track.Done()
+
+			for match := range queue {
+				if route, err := legs.FindPath(ctx, origin, match.System, cons); err == nil {
+					match.Jumps = len(route.Stops) - 1
+				} else {
+					match.Jumps = -1
+				}
+			}
+		}()
+	}
+
+	for _, match := range matches {
+		queue <- match
+	}
+
+	close(queue)
+	track.Wait()
+}
+
+/**
+ * Builds the routing constraints described by the request. See the /route handler for the
+ * params that are supported.
+ */
+func parseConstraints(ctx *gin.Context) (structs.RoutingConstraints, error) {
+	constraints := structs.RoutingConstraints{MaxJump: 18.0, MaxHops: 200, MaxExpansions: config.MaxExpansions}
+
+	ship, err := parseShip(ctx)
+	if err != nil {
+		return constraints, err
+	}
+	constraints.Ship = ship
+
+	if err := queryNumber(ctx, "jump", &constraints.MaxJump); err != nil {
+		return constraints, err
+	}
+
+	constraints.Supercharge = ctx.Query("supercharge") == "true"
+	constraints.AvoidHazards = ctx.Query("avoidhazards") == "true"
+	constraints.MinimizeTime = ctx.Query("optimize") == "time"
+	constraints.Greedy = ctx.Query("mode") == structs.SearchGreedy
+
+	if err := queryNumber(ctx, "weight", &constraints.Weight); err != nil {
+		return constraints, err
+	}
+
+	if len(ctx.Query("tank")) > 0 {
+		if err := queryNumber(ctx, "tank", &constraints.TankSize); err != nil {
+			return constraints, err
+		}
+
+		if err := queryNumber(ctx, "jumpfuel", &constraints.FuelPerJump); err != nil {
+			return constraints, err
+		}
+	}
+
+	if err := queryNumber(ctx, "scooprate", &constraints.ScoopRate); err != nil {
+		return constraints, err
+	}
+
+	if len(ctx.Query("avoid")) > 0 {
+		constraints.AvoidSystems = make(map[structs.SystemID]bool)
+
+		for _, rawID := range strings.Split(ctx.Query("avoid"), ",") {
+			id, err := strconv.Atoi(rawID)
+			if err != nil {
+				return constraints, errors.New("invalid system id in avoid: " + rawID)
+			}
+
+			constraints.AvoidSystems[structs.SystemID(id)] = true
+		}
+	}
+
+	if pad := strings.ToUpper(ctx.Query("pad")); pad != "" {
+		if pad != "S" && pad != "M" && pad != "L" {
+			return constraints, errors.New("pad should be S, M or L")
+		}
+
+		constraints.PadSize = pad
+	}
+
+	if ctx.Query("permits") == "all" {
+		constraints.IgnorePermits = true
+	} else if len(ctx.Query("permits")) > 0 {
+		constraints.Permits = make(map[structs.SystemID]bool)
+
+		for _, rawID := range strings.Split(ctx.Query("permits"), ",") {
+			id, err := strconv.Atoi(rawID)
+			if err != nil {
+				return constraints, errors.New("invalid system id in permits: " + rawID)
+			}
+
+			constraints.Permits[structs.SystemID(id)] = true
+		}
+	}
+
+	if len(ctx.Query("avoidtype")) > 0 {
+		for _, name := range strings.Split(ctx.Query("avoidtype"), ",") {
+			if _, exists := structs.Filters[name]; !exists {
+				return constraints, errors.New("unknown filter in avoidtype: " + name)
+			}
+
+			constraints.AvoidTypes = append(constraints.AvoidTypes, name)
+		}
+	}
+
+	if len(ctx.Query("penalty")) > 0 {
+		constraints.Penalties = make(map[string]float64)
+
+		for _, pair := range strings.Split(ctx.Query("penalty"), ",") {
+			parts := strings.SplitN(pair, ":", 2)
+			if _, exists := structs.Filters[parts[0]]; !exists || len(parts) != 2 {
+				return constraints, errors.New("penalties should be given as filter:penalty")
+			}
+
+			penalty, err := parseNumber("penalty", parts[1])
+			if err != nil || penalty < 0 {
+				return constraints, errors.New("invalid penalty for " + parts[0])
+			}
+
+			constraints.Penalties[parts[0]] = penalty
+		}
+	}
+
+	for _, raw := range ctx.QueryArray("avoidregion") {
+		region, err := parseRegion(raw)
+		if err != nil {
+			return constraints, err
+		}
+
+		constraints.AvoidRegions = append(constraints.AvoidRegions, region)
+	}
+
+	return constraints, nil
+}
+
+/**
+ * Parses a number given as a request param. Numbers that don't parse, or that aren't finite
+ * (like `NaN`, `Inf` or `1e400`), are rejected.
+ */
+func parseNumber(name string, raw string) (float64, error) {
+	value, err := strconv.ParseFloat(strings.TrimSpace(raw), 64)
+	if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+		return 0, errors.New(name + " should be a number")
+	}
+
+	return value, nil
+}
+
+/**
+ * Parses the named request param into `value` with parseNumber, if it was given. Otherwise
+ * `value` is left as it is.
+ */
+func queryNumber(ctx *gin.Context, name string, value *float64) error {
+	if len(ctx.Query(name)) == 0 {
+		return nil
+	}
+
+	parsed, err := parseNumber(name, ctx.Query(name))
+	if err != nil {
+		return err
+	}
+
+	*value = parsed
+	return nil
+}
+
+/**
+ * Parses a region given as `x,y,z,radius`.
+ */
+func parseRegion(raw string) (structs.AvoidRegion, error) {
+	var values [4]float64
+
+	parts := strings.Split(raw, ",")
+	if len(parts) != len(values) {
+		return structs.AvoidRegion{}, errors.New("regions should be given as x,y,z,radius")
+	}
+
+	for i, part := range parts {
+		value, err := parseNumber("region", part)
+		if err != nil {
+			return structs.AvoidRegion{}, errors.New("invalid number in region: " + part)
+		}
+
+		values[i] = value
+	}
+
+	return structs.AvoidRegion{X: values[0], Y: values[1], Z: values[2], Radius: values[3]}, nil
+}
+
+/**
+ * Builds the ship described by the request, if there is one. `ship` can either be the name of
+ * one of the loadouts in structs.Ships or `custom`, in which case the FSD is described by `fsd`
+ * (like "5A") plus `optmass` and `maxfuel`, and the ship by `mass` (unladen), `tank` and `pad`
+ * (S, M or L). Custom ships without a pad are assumed to need a large one, so that routes never
+ * send them to a station they can't land at. Any ship can also be given `cargo` (tons carried)
+ * and `booster` (guardian FSD booster class).
+ */
+func parseShip(ctx *gin.Context) (*structs.Ship, error) {
+	name := strings.ToLower(ctx.Query("ship"))
+	if name == "" {
+		return nil, nil
+	}
+
+	var ship structs.Ship
+	if name == "custom" {
+		class, rating, err := structs.ParseFSD(ctx.Query("fsd"))
+		if err != nil {
+			return nil, err
+		}
+
+		ship = structs.Ship{Name: "Custom", FSDClass: class, FSDRating: rating}
+		for _, param := range []struct {
+			name  string
+			value *float64
+		}{
+			{"mass", &ship.HullMass},
+			{"optmass", &ship.OptimalMass},
+			{"maxfuel", &ship.MaxFuelPerJump},
+			{"tank", &ship.FuelCapacity},
+		} {
+			if err := queryNumber(ctx, param.name, param.value); err != nil {
+				return nil, err
+			}
+		}
+
+		ship.PadSize = strings.ToUpper(ctx.Query("pad"))
+		if ship.PadSize == "" {
+			ship.PadSize = "L"
+		}
+
+		if ship.HullMass <= 0 || ship.OptimalMass <= 0 || ship.MaxFuelPerJump <= 0 {
+			return nil, errors.New("custom ships need a mass, optmass and maxfuel")
+		}
+	} else if preset, exists := structs.Ships[name]; exists {
+		ship = preset
+	} else {
+		return nil, errors.New("unknown ship " + name)
+	}
+
+	if err := queryNumber(ctx, "cargo", &ship.CargoMass); err != nil {
+		return nil, err
+	}
 
-		if end != 0 {
-			nextIds = append(nextIds, end)
+	if len(ctx.Query("booster")) > 0 {
+		booster, err := strconv.Atoi(strings.TrimSpace(ctx.Query("booster")))
+		if err != nil {
+			return nil, errors.New("booster should be a whole number")
 		}
 
-		variants = append(variants, nextIds)
+		ship.Booster = booster
 	}
 
-	return variants
+	return &ship, nil
 }
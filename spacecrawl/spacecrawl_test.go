@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anyweez/edpaths/structs"
+	"github.com/anyweez/edpaths/structs/gen"
+	"github.com/gin-gonic/gin"
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+)
+
+/**
+ * Builds a router over a line of systems along the x axis, each 10 LY from the last, with ids
+ * starting at 1.
+ */
+func testRouter(count int) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	config.TourBudget = time.Second
+	config.RouteTimeout = 10 * time.Second
+
+	universe := &space.Universe{}
+	for i := 0; i < count; i++ {
+		universe.Systems = append(universe.Systems, &space.SpaceSystem{
+			SystemID: proto.Int32(int32(i + 1)),
+			Name:     proto.String("Waypoint " + strconv.Itoa(i+1)),
+			X:        proto.Float64(float64(i) * 10),
+			Y:        proto.Float64(0),
+			Z:        proto.Float64(0),
+		})
+	}
+
+	db := structs.NewSpaceDB(universe)
+	return newRouter(db, structs.InitGraph(100).Load(db), nil)
+}
+
+func getRoute(router *gin.Engine, query string) RouteResponse {
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/route?"+query, nil))
+
+	var response RouteResponse
+	json.Unmarshal(recorder.Body.Bytes(), &response)
+
+	return response
+}
+
+func TestRouteManyWaypoints(t *testing.T) {
+	count := structs.HeldKarpLimit + 7
+	router := testRouter(count)
+
+	// Too many stops to order exactly, so they're handed to the heuristics. Visiting them in a
+	// jumbled order should still come out as a straight line.
+	var visit []string
+	for i := count - 1; i > 1; i -= 2 {
+		visit = append(visit, strconv.Itoa(i))
+	}
+	for i := 2; i < count; i += 2 {
+		visit = append(visit, strconv.Itoa(i))
+	}
+
+	response := getRoute(router, "from=1&to="+strconv.Itoa(count)+"&visit="+strings.Join(visit, ","))
+	if assert.Equal(t, http.StatusOK, response.Status, response.Error) {
+		var requested []structs.SystemID
+		for _, stop := range response.Route.Stops {
+			if stop.RequestedStop {
+				requested = append(requested, stop.System.ID)
+			}
+		}
+
+		expected := make([]structs.SystemID, count)
+		for i := range expected {
+			expected[i] = structs.SystemID(i + 1)
+		}
+
+		assert.Equal(t, expected, requested)
+	}
+
+	// There's still a limit, though.
+	visit = visit[:0]
+	for i := 0; i < maxWaypoints; i++ {
+		visit = append(visit, "2")
+	}
+
+	response = getRoute(router, "from=1&to=3&visit="+strings.Join(visit, ","))
+	assert.Equal(t, http.StatusBadRequest, response.Status)
+	assert.Equal(t, codeBadRequest, response.Code)
+}
+
+func getNearest(router *gin.Engine, query string) NearestResponse {
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/nearest?"+query, nil))
+
+	var response NearestResponse
+	json.Unmarshal(recorder.Body.Bytes(), &response)
+
+	return response
+}
+
+func TestNearestJumps(t *testing.T) {
+	router := testRouter(10)
+
+	// More matches than workers, so they have to share.
+	defer func(workers int) { legWorkers = workers }(legWorkers)
+	legWorkers = 2
+
+	response := getNearest(router, "from=1&limit=9&jumps=true&jump=15")
+	if assert.Equal(t, http.StatusOK, response.Status, response.Error) && assert.Equal(t, 9, len(response.Systems)) {
+		for _, match := range response.Systems {
+			assert.Equal(t, int(match.System.ID)-1, match.Jumps, "system %d", match.System.ID)
+		}
+	}
+
+	// Nothing is in range with shorter jumps.
+	response = getNearest(router, "from=1&limit=3&jumps=true&jump=5")
+	if assert.Equal(t, http.StatusOK, response.Status, response.Error) {
+		for _, match := range response.Systems {
+			assert.Equal(t, -1, match.Jumps)
+		}
+	}
+}
+
+func TestRouteBadNumbers(t *testing.T) {
+	router := testRouter(3)
+
+	for _, query := range []string{
+		"jump=fast",
+		"jump=NaN",
+		"weight=1e400",
+		"tank=32&jumpfuel=lots",
+		"scooprate=Inf",
+		"penalty=anarchy:NaN",
+		"avoidregion=0,0,0,-Inf",
+		"ship=custom&fsd=5A&mass=heavy&optmass=1050&maxfuel=5",
+		"ship=custom&fsd=5A&mass=300&optmass=1050&maxfuel=5&tank=NaN",
+		"ship=sidewinder&cargo=some",
+		"ship=sidewinder&booster=1.5",
+	} {
+		response := getRoute(router, "from=1&to=3&"+query)
+		assert.Equal(t, http.StatusBadRequest, response.Status, query)
+		assert.Equal(t, codeBadRequest, response.Code, query)
+	}
+
+	response := getRoute(router, "from=1&to=3&jump=25&weight=1.5&scooprate=0.5")
+	assert.Equal(t, http.StatusOK, response.Status, response.Error)
+}
@@ -3,8 +3,8 @@ package main
 /**
  * This application is responsible for ingesting, transforming, and outputting datasets into a form that's
  * ready for consumption by the service. It's currently reading in all systems and stations and outputting
- * them into Bolt (https://github.com/boltdb/bolt) databases as well as a simple <name>,<id> pairing in CSV's
- * for the purposes of autocomplete.
+ * them into protobuf databases (data/systems.db, plus data/sample.db for a small area of space). Bodies
+ * go into their own stores next to them (data/systems-bodies.db and data/sample-bodies.db).
  *
  * This application should be run once whenever new data is downloaded.
  */
@@ -12,6 +12,7 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"os"
@@ -23,62 +24,52 @@ import (
 )
 
 /**
- * Reads in all station data and adds each record to a Bolt database keyed by station ID.
- * Also outputs a CSV containing all station names and their ID.
+ * Read all stations and push them out into the provided channel once they're available. eddb
+ * uses "None" as the pad size of stations that ships can't land at.
  */
-// func stations() {
-// 	fp, _ := os.Open("data/stations.json")
-// 	decoder := json.NewDecoder(fp)
-
-// 	db, _ := bolt.Open("data/stations.db", 0600, &bolt.Options{Timeout: 1 * time.Second})
-// 	db.Update(func(tx *bolt.Tx) error {
-// 		tx.CreateBucketIfNotExists([]byte("stations"))
-// 		return nil
-// 	})
-// 	defer db.Close()
-
-// 	out, _ := os.Create("data/stations.csv")
-// 	csv := csv.NewWriter(out)
-// 	csv.Write([]string{"name", "id"})
-
-// 	decoder.Token()
-// 	for decoder.More() {
-// 		var station structs.SpaceStation
-
-// 		if err := decoder.Decode(&station); err != nil {
-// 			log.Fatal(err)
-// 		}
-
-// 		// Update the database
-// 		db.Update(func(tx *bolt.Tx) error {
-// 			b := tx.Bucket([]byte("stations"))
-
-// 			raw := new(bytes.Buffer)
-// 			gob.NewEncoder(raw).Encode(station)
-// 			b.Put([]byte(strconv.Itoa(int(station.ID))), raw.Bytes())
-
-// 			return nil
-// 		})
-
-// 		// Update the CSV
-// 		csv.Write([]string{station.Name, strconv.Itoa(int(station.ID))})
-// 	}
-// 	decoder.Token()
-// 	csv.Flush()
-
-// 	if err := csv.Error(); err != nil {
-// 		log.Fatal(err)
-// 	}
-// }
-
-func systems(in chan structs.SpaceSystem, status *sync.WaitGroup) {
+func LoadStations(out chan structs.SpaceStation) {
+	fp, _ := os.Open("data/stations.json")
+	decoder := json.NewDecoder(fp)
+
+	decoder.Token()
+	for decoder.More() {
+		var station structs.SpaceStation
+
+		if err := decoder.Decode(&station); err != nil {
+			log.Fatal(err)
+		}
+
+		if station.MaxLandingPad == "None" {
+			station.MaxLandingPad = ""
+		}
+
+		out <- station
+	}
+
+	decoder.Token()
+	close(out)
+}
+
+func systems(in chan structs.SpaceSystem, stations chan structs.SpaceStation, bodies chan structs.SpaceBody, status *sync.WaitGroup) {
 	// Set up (new) or load (existing) database and prepare to make some changes.
 	full := space.Universe{}
 	sample := space.Universe{}
 
 	centroid := structs.SpaceSystem{X: 100, Y: 100, Z: 100}
+	sampled := make(map[structs.SystemID]bool)
+	written := make(map[structs.SystemID]*space.SpaceSystem)
 	var nextSystem *space.SpaceSystem
 
+	// Bodies are read before systems, and there are far too many of them to hold on to, so
+	// they're written out as they come in. The sample's bodies are copied out afterwards.
+	writeBodies("data/systems-bodies.db", func(out *structs.BodyWriter) {
+		for body := range bodies {
+			if err := out.Write(bodyRecord(body)); err != nil {
+				log.Fatal(err)
+			}
+		}
+	})
+
 	for system := range in {
 		id := int32(system.ID)
 
@@ -90,15 +81,80 @@ func systems(in chan structs.SpaceSystem, status *sync.WaitGroup) {
 		nextSystem.Z = proto.Float64(system.Z)
 		nextSystem.ContainsScoopableStar = proto.Bool(system.ContainsScoopableStar)
 		nextSystem.ContainsRefuelStation = proto.Bool(system.ContainsRefuelStation)
+		nextSystem.ArrivalStarClass = proto.String(system.ArrivalStarClass)
+		nextSystem.ArrivalNeutronStar = proto.Bool(system.ArrivalNeutronStar)
+		nextSystem.ArrivalWhiteDwarf = proto.Bool(system.ArrivalWhiteDwarf)
+		nextSystem.ArrivalBlackHole = proto.Bool(system.ArrivalBlackHole)
+		nextSystem.NeedsPermit = proto.Bool(system.NeedsPermit)
+		nextSystem.Security = proto.String(system.Security)
+		nextSystem.Government = proto.String(system.Government)
+		nextSystem.Allegiance = proto.String(system.Allegiance)
+		nextSystem.States = system.States
 
 		full.Systems = append(full.Systems, nextSystem)
+		written[system.ID] = nextSystem
 
 		// Update the sample DB iff its within 100 LY of the definied centroid
 		if centroid.DistanceTo(&system) < 100 {
 			sample.Systems = append(sample.Systems, nextSystem)
+			sampled[system.ID] = true
 		}
 	}
 
+	// Stations are stored alongside the systems they're in.
+	for station := range stations {
+		nextStation := new(space.SpaceStation)
+		nextStation.StationID = proto.Int32(int32(station.ID))
+		nextStation.Name = proto.String(station.Name)
+		nextStation.SystemID = proto.Int32(int32(station.SystemID))
+		nextStation.DistanceToStar = proto.Int32(int32(station.DistanceToStar))
+		nextStation.Type = proto.String(station.Type)
+		nextStation.MaxLandingPadSize = proto.String(station.MaxLandingPad)
+		nextStation.HasRefuel = proto.Bool(station.HasRefuel)
+		nextStation.HasRepair = proto.Bool(station.HasRepair)
+		nextStation.HasRearm = proto.Bool(station.HasRearm)
+		nextStation.HasShipyard = proto.Bool(station.HasShipyard)
+		nextStation.HasOutfitting = proto.Bool(station.HasOutfitting)
+		nextStation.HasMaterialTrader = proto.Bool(station.HasMaterialTrader)
+		nextStation.HasInterstellarFactors = proto.Bool(station.HasInterstellarFactors)
+
+		full.Stations = append(full.Stations, nextStation)
+
+		// Systems can be refuelled at if any station that sells fuel has a landing pad. Keep
+		// track of the biggest pad so that routes can tell whether a ship will fit.
+		if parent, exists := written[station.SystemID]; exists && station.HasRefuel && station.MaxLandingPad != "" {
+			parent.ContainsRefuelStation = proto.Bool(true)
+
+			if !structs.PadFits(parent.GetRefuelPadSize(), station.MaxLandingPad) {
+				parent.RefuelPadSize = proto.String(station.MaxLandingPad)
+			}
+		}
+
+		if sampled[station.SystemID] {
+			sample.Stations = append(sample.Stations, nextStation)
+		}
+	}
+
+	catalog, err := structs.OpenBodies("data/systems-bodies.db")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	writeBodies("data/sample-bodies.db", func(out *structs.BodyWriter) {
+		for id := range sampled {
+			found, err := catalog.BodiesIn(id)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			for _, body := range found {
+				if err := out.Write(bodyRecord(*body)); err != nil {
+					log.Fatal(err)
+				}
+			}
+		}
+	})
+
 	fullOut, _ := proto.Marshal(&full)
 	sampleOut, _ := proto.Marshal(&sample)
 
@@ -108,77 +164,148 @@ func systems(in chan structs.SpaceSystem, status *sync.WaitGroup) {
 	status.Done()
 }
 
+/**
+ * Creates a body store at the path and hands a writer for it to `write`, flushing it afterwards.
+ */
+func writeBodies(path string, write func(*structs.BodyWriter)) {
+	fp, err := os.Create(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer fp.Close()
+
+	out := structs.NewBodyWriter(fp)
+	write(out)
+
+	if err := out.Flush(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func bodyRecord(body structs.SpaceBody) *space.SpaceBody {
+	record := new(space.SpaceBody)
+	record.BodyID = proto.Int32(int32(body.ID))
+	record.Name = proto.String(body.Name)
+	record.SystemID = proto.Int32(int32(body.SystemID))
+	record.Group = proto.String(body.GroupName)
+	record.Type = proto.String(body.TypeName)
+	record.SpectralClass = proto.String(body.SpectralClass)
+	record.IsMainStar = proto.Bool(body.IsMainStar)
+	record.IsLandable = proto.Bool(body.IsLandable)
+	record.TerraformingState = proto.String(body.TerraformingState)
+	record.DistanceToArrival = proto.Float64(body.DistanceToArrival)
+
+	for _, ring := range body.Rings {
+		record.Rings = append(record.Rings, &space.SpaceRing{
+			Name:        proto.String(ring.Name),
+			Type:        proto.String(ring.TypeName),
+			InnerRadius: proto.Float64(ring.InnerRadius),
+			OuterRadius: proto.Float64(ring.OuterRadius),
+			Mass:        proto.Float64(ring.Mass),
+		})
+	}
+
+	return record
+}
+
 func main() {
 	var status sync.WaitGroup
 	status.Add(1)
 
 	sys := make(chan structs.SpaceSystem, 100)
+	sta := make(chan structs.SpaceStation, 100)
+	bod := make(chan structs.SpaceBody, 100)
 
-	fmt.Println("Reading system data...")
-	go LoadSystems(sys)
-	go systems(sys, &status)
+	fmt.Println("Reading system, station and body data...")
+	go LoadSystems(sys, bod)
+	go LoadStations(sta)
+	go systems(sys, sta, bod, &status)
 
 	status.Wait()
 }
 
 /**
- * Read all systems and bodies and push them out into the provided channel once they're
- * available.
+ * A system as it appears in systems.json. States are objects there rather than names, and older
+ * dumps only have a single `state`.
  */
-func LoadSystems(out chan structs.SpaceSystem) {
-	// Load bodies first and generate the `powered` map
-	powered := make(map[structs.SystemID]bool)
+type systemRecord struct {
+	structs.SpaceSystem
+
+	State  string `json:"state"`
+	States []struct {
+		Name string `json:"name"`
+	} `json:"states"`
+}
 
-	scoopable := []string{"O", "B", "A", "F", "G", "K", "M"}
+/**
+ * Read all systems and bodies and push them out into the provided channels once they're
+ * available. All of the bodies are sent (and the channel closed) before any systems are.
+ */
+func LoadSystems(out chan structs.SpaceSystem, bodies chan structs.SpaceBody) {
+	// Load bodies first so we know which star ships arrive at in each system.
 	bfp, _ := os.Open("data/bodies.json")
-	bDecoder := json.NewDecoder(bfp)
+	arrival := readBodies(bfp, bodies)
+	close(bodies)
 
-	bDecoder.Token()
-	for bDecoder.More() {
-		var body structs.SpaceBody
+	// Load systems
+	fp, _ := os.Open("data/systems.json")
+	decoder := json.NewDecoder(fp)
+
+	decoder.Token()
+	for decoder.More() {
+		var record systemRecord
 
-		if err := bDecoder.Decode(&body); err != nil {
+		if err := decoder.Decode(&record); err != nil {
 			log.Fatal(err)
 		}
 
-		// If we're dealing with a star, check to see whether its scoopable
-		_, exists := powered[body.SystemID]
-		if !exists {
-			powered[body.SystemID] = false
+		// eddb uses "None" for systems that aren't in any state.
+		system := record.SpaceSystem
+		for _, state := range record.States {
+			if state.Name != "None" {
+				system.States = append(system.States, state.Name)
+			}
 		}
 
-		if body.GroupID == 2 {
-			for _, class := range scoopable {
-				if body.SpectralClass == class {
-					powered[body.SystemID] = true
-				}
-			}
+		if len(record.States) == 0 && record.State != "" && record.State != "None" {
+			system.States = []string{record.State}
 		}
+
+		system.SetArrivalStar(arrival[system.ID])
+
+		out <- system
 	}
 
-	bDecoder.Token()
+	decoder.Token()
+	close(out)
+}
 
-	// Load systems
-	fp, _ := os.Open("data/systems.json")
-	decoder := json.NewDecoder(fp)
+/**
+ * Reads bodies in the bodies.json format, sends each of them to the channel, and returns the main
+ * star of every system that has one. Systems can have several stars but eddb only flags the one
+ * that ships arrive at as the main star, and that's the one we care about.
+ */
+func readBodies(r io.Reader, bodies chan structs.SpaceBody) map[structs.SystemID]*structs.SpaceBody {
+	arrival := make(map[structs.SystemID]*structs.SpaceBody)
+	decoder := json.NewDecoder(r)
 
 	decoder.Token()
 	for decoder.More() {
-		var system structs.SpaceSystem
+		var body structs.SpaceBody
 
-		if err := decoder.Decode(&system); err != nil {
+		if err := decoder.Decode(&body); err != nil {
 			log.Fatal(err)
 		}
 
-		if status, exists := powered[system.ID]; exists {
-			system.ContainsScoopableStar = status
-		} else {
-			system.ContainsScoopableStar = false
+		if body.IsMainStar {
+			star := body
+			arrival[body.SystemID] = &star
 		}
 
-		out <- system
+		bodies <- body
 	}
 
 	decoder.Token()
-	close(out)
+
+	return arrival
 }
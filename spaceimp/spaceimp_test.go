@@ -0,0 +1,54 @@
+package main
+
+import (
+	"os"
+	"testing"
+
+	"github.com/anyweez/edpaths/structs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestArrivalStars(t *testing.T) {
+	fp, err := os.Open("testdata/bodies.json")
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer fp.Close()
+
+	bodies := make(chan structs.SpaceBody, 100)
+	arrival := readBodies(fp, bodies)
+	close(bodies)
+
+	// Every body is passed along, not just the stars.
+	assert.Equal(t, 12, len(bodies))
+
+	expected := map[structs.SystemID]structs.SpaceSystem{
+		// A scoopable main star with a neutron star companion.
+		1: {ArrivalStarClass: "K", ContainsScoopableStar: true},
+		// A neutron star with a scoopable companion that's too far out to be useful.
+		2: {ArrivalStarClass: "N", ArrivalNeutronStar: true},
+		// Scoopable companions don't make up for an unscoopable main star.
+		3: {ArrivalStarClass: "TTS"},
+		4: {ArrivalStarClass: "DA", ArrivalWhiteDwarf: true},
+		// No stars at all, so nothing is known about the arrival star.
+		5: {},
+		// Black holes don't always come with a spectral class.
+		6: {ArrivalBlackHole: true},
+	}
+
+	for id, want := range expected {
+		system := structs.SpaceSystem{ID: id}
+		system.SetArrivalStar(arrival[id])
+
+		assert.Equal(t, want.ArrivalStarClass, system.ArrivalStarClass, "system %d", id)
+		assert.Equal(t, want.ContainsScoopableStar, system.ContainsScoopableStar, "system %d", id)
+		assert.Equal(t, want.ArrivalNeutronStar, system.ArrivalNeutronStar, "system %d", id)
+		assert.Equal(t, want.ArrivalWhiteDwarf, system.ArrivalWhiteDwarf, "system %d", id)
+		assert.Equal(t, want.ArrivalBlackHole, system.ArrivalBlackHole, "system %d", id)
+	}
+
+	// Setting the arrival star again replaces anything that was there before.
+	system := structs.SpaceSystem{ArrivalStarClass: "K", ContainsScoopableStar: true}
+	system.SetArrivalStar(arrival[2])
+	assert.False(t, system.ContainsScoopableStar)
+}
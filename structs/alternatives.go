@@ -0,0 +1,200 @@
+package structs
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+)
+
+/**
+ * One of the routes found by FindAlternatives, along with how it compares to the best route.
+ * The differences are all zero for the best route itself.
+ */
+type AlternativeRoute struct {
+	Route *SpaceRoute
+
+	ExtraCost     float64
+	ExtraDistance float64
+	ExtraJumps    int
+	Detour        []SystemID // systems on this route that the best route doesn't pass through
+}
+
+/**
+ * Finds up to k loopless routes between two systems, cheapest first, using Yen's algorithm. The
+ * first route is the one FindPath finds. Each of the others is found by taking one of the routes
+ * found so far, following it up to some stop (the spur), and then searching from there to the
+ * destination without going back through any earlier stop or leaving the spur the same way as
+ * any route that's already been found with the same start.
+ *
+ * Fewer than k routes are returned if there aren't that many, or if the context is cancelled
+ * once the best route has been found. Errors are only returned if there's no route at all.
+ */
+func (graph *SpaceGraph) FindAlternatives(ctx context.Context, from *SpaceSystem, to *SpaceSystem, cons *RoutingConstraints, k int) ([]*AlternativeRoute, error) {
+	best, err := graph.FindPath(ctx, from, to, cons)
+	if err != nil {
+		return nil, err
+	}
+
+	found := []*SpaceRoute{best}
+	seen := map[string]bool{routeKey(best.Stops): true}
+	candidates := &routeQueue{}
+
+	for len(found) < k && ctx.Err() == nil {
+		last := found[len(found)-1]
+
+		for i := 0; i < len(last.Stops)-1 && ctx.Err() == nil; i++ {
+			if candidate := graph.spur(ctx, found, last, i, to, cons); candidate != nil && !seen[routeKey(candidate.Stops)] {
+				seen[routeKey(candidate.Stops)] = true
+				heap.Push(candidates, candidate)
+			}
+		}
+
+		if candidates.Len() == 0 {
+			break
+		}
+
+		found = append(found, heap.Pop(candidates).(*SpaceRoute))
+	}
+
+	visited := make(map[SystemID]bool)
+	for _, stop := range best.Stops {
+		visited[stop.System.ID] = true
+	}
+
+	alternatives := make([]*AlternativeRoute, len(found))
+	for i, route := range found {
+		alternatives[i] = &AlternativeRoute{
+			Route:         route,
+			ExtraCost:     route.Cost - best.Cost,
+			ExtraDistance: route.Distance - best.Distance,
+			ExtraJumps:    len(route.Stops) - len(best.Stops),
+		}
+
+		for _, stop := range route.Stops {
+			if !visited[stop.System.ID] {
+				alternatives[i].Detour = append(alternatives[i].Detour, stop.System.ID)
+			}
+		}
+	}
+
+	return alternatives, nil
+}
+
+/**
+ * Finds the cheapest route that follows `last` up to stop i and then branches off somewhere
+ * new, or returns nil if there isn't one.
+ */
+func (graph *SpaceGraph) spur(ctx context.Context, found []*SpaceRoute, last *SpaceRoute, i int, to *SpaceSystem, cons *RoutingConstraints) *SpaceRoute {
+	root := last.Stops[:i+1]
+	spurStop := root[i]
+
+	// Don't go back through the root, and don't leave the spur the same way as any route
+	// that's already been found with the same root.
+	blocked := &spurBlocks{
+		systems: make(map[*SpaceSystem]bool),
+		jumps:   make(map[*SpaceSystem]bool),
+		spur:    spurStop.System,
+	}
+
+	for _, stop := range root[:i] {
+		blocked.systems[stop.System] = true
+	}
+
+	for _, route := range found {
+		if len(route.Stops) > i+1 && routeKey(route.Stops[:i+1]) == routeKey(root) {
+			blocked.jumps[route.Stops[i+1].System] = true
+		}
+	}
+
+	// Pick up the search with whatever fuel the ship arrived at the spur with.
+	fuel := spurStop.FuelRemaining
+	spur := *cons
+	spur.StartFuel = &fuel
+	hops := cons.HopLimit()
+	spur.Hops = HopLimitFunc(func(jumps int) bool { return hops.AllowsHops(jumps + i) })
+	spur.blocked = blocked
+
+	branch, err := graph.FindPath(ctx, spurStop.System, to, &spur)
+	if err != nil {
+		return nil
+	}
+
+	// The spur stop comes from the branch, since that's where refuelling there was planned.
+	stops := make([]*SpaceStop, 0, i+len(branch.Stops))
+	for _, stop := range root[:i] {
+		copied := *stop
+		stops = append(stops, &copied)
+	}
+
+	stops = append(stops, branch.Stops...)
+	stops[i].DistanceFromPrev = spurStop.DistanceFromPrev
+
+	distance := 0.0
+	for _, stop := range stops {
+		distance += stop.DistanceFromPrev
+	}
+
+	return &SpaceRoute{
+		Origin:      last.Origin,
+		Destination: branch.Destination,
+		Stops:       stops,
+		Distance:    distance,
+		Cost:        cons.PathCost(stops),
+		Duration:    cons.Duration(stops),
+		Mode:        last.Mode,
+		Checks:      branch.Checks,
+	}
+}
+
+/**
+ * Systems that a spur search can't pass through, and systems it can't jump to directly from the
+ * spur. The search can't come back through the spur either, even though it might be able to get
+ * there with more fuel by refuelling nearby.
+ */
+type spurBlocks struct {
+	systems map[*SpaceSystem]bool
+	jumps   map[*SpaceSystem]bool
+	spur    *SpaceSystem
+}
+
+func (blocked *spurBlocks) prevents(from *SpaceSystem, to *SpaceSystem) bool {
+	if blocked == nil {
+		return false
+	}
+
+	return blocked.systems[to] || to == blocked.spur || (from == blocked.spur && blocked.jumps[to])
+}
+
+/**
+ * Identifies a route by the systems it passes through.
+ */
+func routeKey(stops []*SpaceStop) string {
+	ids := make([]SystemID, len(stops))
+	for i, stop := range stops {
+		ids[i] = stop.System.ID
+	}
+
+	return fmt.Sprint(ids)
+}
+
+/**
+ * Candidate routes, cheapest first.
+ */
+type routeQueue struct {
+	routes []*SpaceRoute
+}
+
+func (q *routeQueue) Len() int           { return len(q.routes) }
+func (q *routeQueue) Less(i, j int) bool { return q.routes[i].Cost < q.routes[j].Cost }
+func (q *routeQueue) Swap(i, j int)      { q.routes[i], q.routes[j] = q.routes[j], q.routes[i] }
+
+func (q *routeQueue) Push(x interface{}) {
+	q.routes = append(q.routes, x.(*SpaceRoute))
+}
+
+func (q *routeQueue) Pop() interface{} {
+	last := q.routes[len(q.routes)-1]
+	q.routes = q.routes[:len(q.routes)-1]
+
+	return last
+}
@@ -0,0 +1,152 @@
+package structs
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindAlternatives(t *testing.T) {
+	// The detour is moved a little so that the two routes through both it and the step don't
+	// cost the same.
+	graph := InitGraph(1000)
+	for _, system := range chokepointSystems() {
+		if system.ID == 3 {
+			system.X, system.Y = 3.8, 3.5
+		}
+		graph.Add(system)
+	}
+
+	cons := &RoutingConstraints{MaxJump: 5.5, MaxHops: 10}
+
+	alternatives, err := graph.FindAlternatives(context.Background(), graph.Get(1), graph.Get(5), cons, 10)
+	assert.Nil(t, err)
+
+	var paths [][]SystemID
+	for _, alternative := range alternatives {
+		paths = append(paths, systemIDs(stopSystems(alternative.Route.Stops)))
+	}
+
+	// Those are the only loopless routes there are.
+	assert.Equal(t, [][]SystemID{{1, 2, 4, 5}, {1, 3, 4, 5}, {1, 3, 2, 4, 5}, {1, 2, 3, 4, 5}}, paths)
+
+	best := alternatives[0]
+	assert.Equal(t, 0.0, best.ExtraCost)
+	assert.Equal(t, 0, best.ExtraJumps)
+	assert.Nil(t, best.Detour)
+
+	detour := alternatives[1]
+	assert.InDelta(t, detour.Route.Distance-12, detour.ExtraCost, 0.001)
+	assert.InDelta(t, detour.Route.Cost, detour.Route.Distance, 0.001)
+	assert.Equal(t, []SystemID{3}, detour.Detour)
+	assert.Equal(t, 1, alternatives[3].ExtraJumps)
+
+	for i := 1; i < len(alternatives); i++ {
+		assert.True(t, alternatives[i-1].Route.Cost <= alternatives[i].Route.Cost)
+	}
+
+	alternatives, _ = graph.FindAlternatives(context.Background(), graph.Get(1), graph.Get(5), cons, 2)
+	assert.Equal(t, 2, len(alternatives))
+
+	_, err = graph.FindAlternatives(context.Background(), graph.Get(1), graph.Get(5), &RoutingConstraints{MaxJump: 3, MaxHops: 10}, 3)
+	assert.NotNil(t, err)
+}
+
+func TestFindAlternativesFuel(t *testing.T) {
+	graph := InitGraph(1000)
+	graph.Add(&SpaceSystem{ID: 1, Name: "Origin", X: 0, Y: 0, Z: 0, ContainsRefuelStation: true})
+	graph.Add(&SpaceSystem{ID: 2, Name: "Step", X: 4, Y: 0, Z: 0})
+	graph.Add(&SpaceSystem{ID: 3, Name: "Fork", X: 8, Y: 0, Z: 0})
+	graph.Add(&SpaceSystem{ID: 4, Name: "Station", X: 12, Y: 0, Z: 0, ContainsRefuelStation: true})
+	graph.Add(&SpaceSystem{ID: 5, Name: "Destination", X: 16, Y: 0, Z: 0})
+	graph.Add(&SpaceSystem{ID: 6, Name: "Detour", X: 12, Y: 2.5, Z: 0})
+	graph.Add(&SpaceSystem{ID: 7, Name: "Dead End Station", X: 8, Y: -3.5, Z: 0, ContainsRefuelStation: true})
+
+	cons := &RoutingConstraints{MaxJump: 5, MaxHops: 10, TankSize: 3, FuelPerJump: 1}
+
+	alternatives, err := graph.FindAlternatives(context.Background(), graph.Get(1), graph.Get(5), cons, 5)
+	assert.Nil(t, err)
+
+	var paths [][]SystemID
+	for _, alternative := range alternatives {
+		paths = append(paths, systemIDs(stopSystems(alternative.Route.Stops)))
+	}
+
+	// Going through the detour from the fork needs more fuel than there is, and refuelling at the
+	// dead end would mean passing through the fork twice.
+	assert.Equal(t, [][]SystemID{{1, 2, 3, 4, 5}, {1, 2, 3, 4, 6, 5}}, paths)
+
+	// The ship gets to the station with an empty tank, so it has to refuel there.
+	if assert.Equal(t, 2, len(alternatives)) {
+		station := alternatives[1].Route.Stops[3]
+		assert.Equal(t, 0.0, station.FuelRemaining)
+		assert.True(t, station.Refuel)
+	}
+}
+
+func TestFindAlternativesMatchesBruteForce(t *testing.T) {
+	for trial := 0; trial < 10; trial++ {
+		graph := InitGraph(1000)
+		systems := randomSystems(12, 30)
+		for _, system := range systems {
+			graph.Add(system)
+		}
+
+		cons := &RoutingConstraints{MaxJump: 12, MaxHops: 20}
+		from, to := systems[0], systems[1]
+
+		// The length of every simple path.
+		var lengths []float64
+		visited := map[*SpaceSystem]bool{from: true}
+
+		var walk func(at *SpaceSystem, distance float64)
+		walk = func(at *SpaceSystem, distance float64) {
+			if at == to {
+				lengths = append(lengths, distance)
+				return
+			}
+
+			for _, near := range systems {
+				if visited[near] || at.DistanceTo(near) >= cons.MaxJump {
+					continue
+				}
+
+				visited[near] = true
+				walk(near, distance+at.DistanceTo(near))
+				visited[near] = false
+			}
+		}
+		walk(from, 0)
+		sort.Float64s(lengths)
+
+		alternatives, err := graph.FindAlternatives(context.Background(), from, to, cons, 5)
+		if len(lengths) == 0 {
+			assert.NotNil(t, err)
+			continue
+		}
+
+		assert.Equal(t, min(5, len(lengths)), len(alternatives), "trial %d", trial)
+		for i, alternative := range alternatives {
+			if i < len(lengths) {
+				assert.InDelta(t, lengths[i], alternative.Route.Cost, 0.0001, "trial %d", trial)
+			}
+
+			seen := make(map[SystemID]bool)
+			for _, stop := range alternative.Route.Stops {
+				assert.False(t, seen[stop.System.ID], "trial %d has a loop", trial)
+				seen[stop.System.ID] = true
+			}
+		}
+	}
+}
+
+func stopSystems(stops []*SpaceStop) []*SpaceSystem {
+	systems := make([]*SpaceSystem, len(stops))
+	for i, stop := range stops {
+		systems[i] = stop.System
+	}
+
+	return systems
+}
@@ -0,0 +1,188 @@
+package structs
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"os"
+
+	"github.com/anyweez/edpaths/structs/gen"
+	"github.com/golang/protobuf/proto"
+)
+
+/**
+ * BodyStore looks up the bodies in a system from a file of body records. There are millions of
+ * bodies, so only the position of each system's records is kept in memory; the bodies themselves
+ * are read from the file whenever they're asked for.
+ *
+ * Each record is the system ID and the length of the body as uvarints, followed by the body as
+ * a space.SpaceBody. Records can be in any order (see BodyWriter).
+ */
+type BodyStore struct {
+	data    io.ReaderAt
+	records map[SystemID][]bodyPosition
+}
+
+type bodyPosition struct {
+	offset int64
+	size   int
+}
+
+/**
+ * Opens the body store at the specified path. The file stays open for as long as the store is
+ * being used.
+ */
+func OpenBodies(path string) (*BodyStore, error) {
+	fp, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewBodyStore(fp)
+}
+
+/**
+ * Builds a store out of body records, reading through all of them once to find where each
+ * system's bodies are.
+ */
+func NewBodyStore(data io.ReaderAt) (*BodyStore, error) {
+	store := &BodyStore{
+		data:    data,
+		records: make(map[SystemID][]bodyPosition),
+	}
+
+	reader := &countingReader{r: bufio.NewReader(io.NewSectionReader(data, 0, math.MaxInt64))}
+
+	for {
+		id, err := binary.ReadUvarint(reader)
+		if err == io.EOF {
+			return store, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		size, err := binary.ReadUvarint(reader)
+		if err != nil {
+			return nil, io.ErrUnexpectedEOF
+		}
+
+		position := bodyPosition{offset: reader.read, size: int(size)}
+		if _, err := reader.r.Discard(position.size); err != nil {
+			return nil, io.ErrUnexpectedEOF
+		}
+		reader.read += int64(position.size)
+
+		store.records[SystemID(id)] = append(store.records[SystemID(id)], position)
+	}
+}
+
+/**
+ * Returns all known bodies in the system, or nil if there aren't any.
+ */
+func (store *BodyStore) BodiesIn(id SystemID) ([]*SpaceBody, error) {
+	var bodies []*SpaceBody
+
+	for _, position := range store.records[id] {
+		raw := make([]byte, position.size)
+		if _, err := store.data.ReadAt(raw, position.offset); err != nil {
+			return nil, err
+		}
+
+		record := space.SpaceBody{}
+		if err := proto.Unmarshal(raw, &record); err != nil {
+			return nil, err
+		}
+
+		bodies = append(bodies, newSpaceBody(&record))
+	}
+
+	return bodies, nil
+}
+
+/**
+ * BodyWriter writes body records that a BodyStore can read. Bodies are written as soon as
+ * they're available, so nothing needs to be held on to while a catalog is being imported.
+ * Flush needs to be called once all of the bodies have been written.
+ */
+type BodyWriter struct {
+	out *bufio.Writer
+}
+
+func NewBodyWriter(w io.Writer) *BodyWriter {
+	return &BodyWriter{out: bufio.NewWriter(w)}
+}
+
+func (writer *BodyWriter) Write(body *space.SpaceBody) error {
+	if body.GetSystemID() < 0 {
+		return errors.New("bodies need a system id")
+	}
+
+	raw, err := proto.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	header := make([]byte, 0, 2*binary.MaxVarintLen64)
+	header = binary.AppendUvarint(header, uint64(body.GetSystemID()))
+	header = binary.AppendUvarint(header, uint64(len(raw)))
+
+	if _, err := writer.out.Write(header); err != nil {
+		return err
+	}
+
+	_, err = writer.out.Write(raw)
+	return err
+}
+
+func (writer *BodyWriter) Flush() error {
+	return writer.out.Flush()
+}
+
+/**
+ * Converts a body record into a SpaceBody.
+ */
+func newSpaceBody(record *space.SpaceBody) *SpaceBody {
+	body := &SpaceBody{
+		ID:                int(record.GetBodyID()),
+		Name:              record.GetName(),
+		SystemID:          SystemID(record.GetSystemID()),
+		GroupName:         record.GetGroup(),
+		TypeName:          record.GetType(),
+		SpectralClass:     record.GetSpectralClass(),
+		IsMainStar:        record.GetIsMainStar(),
+		IsLandable:        record.GetIsLandable(),
+		TerraformingState: record.GetTerraformingState(),
+		DistanceToArrival: record.GetDistanceToArrival(),
+	}
+
+	for _, ring := range record.GetRings() {
+		body.Rings = append(body.Rings, SpaceRing{
+			Name:        ring.GetName(),
+			TypeName:    ring.GetType(),
+			InnerRadius: ring.GetInnerRadius(),
+			OuterRadius: ring.GetOuterRadius(),
+			Mass:        ring.GetMass(),
+		})
+	}
+
+	return body
+}
+
+/**
+ * Keeps track of how far into the records we are, so that BodyStore knows where each one starts.
+ */
+type countingReader struct {
+	r    *bufio.Reader
+	read int64
+}
+
+func (reader *countingReader) ReadByte() (byte, error) {
+	b, err := reader.r.ReadByte()
+	if err == nil {
+		reader.read++
+	}
+
+	return b, err
+}
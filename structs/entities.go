@@ -5,6 +5,7 @@ import (
 	"io/ioutil"
 	"log"
 	"math"
+	"strings"
 
 	"github.com/anyweez/edpaths/structs/gen"
 	// "github.com/boltdb/bolt"
@@ -14,15 +15,31 @@ import (
 
 type SystemID int
 
+const (
+	NeutronSupercharge    = 4.0 // jump range multiplier after supercharging at a neutron star
+	WhiteDwarfSupercharge = 1.5 // jump range multiplier after supercharging at a white dwarf
+)
+
 /**
  * SpaceStation is a full representation of an individual station. Currently this only contains
- * navigational data (nothing commercial). It also contains the ID (key) of the system its in.
+ * navigational data and services (nothing commercial). It also contains the ID (key) of the
+ * system its in.
  */
 type SpaceStation struct {
 	ID             SystemID `json:"id"`
-	Name           string
-	DistanceToStar int
-	SystemID       int
+	Name           string   `json:"name"`
+	SystemID       SystemID `json:"system_id"`
+	DistanceToStar int      `json:"distance_to_star"` // light seconds from the arrival star
+	Type           string   `json:"type"`
+	MaxLandingPad  string   `json:"max_landing_pad_size"` // "S", "M" or "L"
+
+	HasRefuel              bool `json:"has_refuel"`
+	HasRepair              bool `json:"has_repair"`
+	HasRearm               bool `json:"has_rearm"`
+	HasShipyard            bool `json:"has_shipyard"`
+	HasOutfitting          bool `json:"has_outfitting"`
+	HasMaterialTrader      bool `json:"has_material_trader"`
+	HasInterstellarFactors bool `json:"has_interstellar_factors"`
 }
 
 /**
@@ -39,20 +56,132 @@ type SpaceSystem struct {
 	Bucket                *SpaceBucket `json:"-"`
 	ContainsScoopableStar bool
 	ContainsRefuelStation bool
+	RefuelPadSize         string `json:"refuel_pad_size"` // largest landing pad at a station that sells fuel
+
+	// Stations in the system that sell fuel, if they've been loaded (see RefuelStation).
+	RefuelStations []*SpaceStation `json:"-"`
+
+	// Spectral class of the star that ships arrive at, like "K" or "N". Empty if it's unknown.
+	ArrivalStarClass string `json:"arrival_star_class"`
+
+	// Arrival stars that supercharge the FSD on the way out of the system.
+	ArrivalNeutronStar bool
+	ArrivalWhiteDwarf  bool
+
+	// Whether ships arrive next to a black hole, which doesn't supercharge anything (see
+	// ArrivalHazard).
+	ArrivalBlackHole bool
+
+	// Whether commanders need a permit to enter the system.
+	NeedsPermit bool `json:"needs_permit"`
+
+	// Political situation in the system, as named by eddb. Security is one of "High", "Medium",
+	// "Low" or "Anarchy", and States holds things like "War", "Lockdown" or "Outbreak".
+	Security   string   `json:"security"`
+	Government string   `json:"government"`
+	Allegiance string   `json:"allegiance"`
+	States     []string `json:"states"`
 }
 
+/**
+ * SpaceBody is a star, planet or other body in a system. GroupName is the broad type of body
+ * (like "Star" or "Planet") and TypeName is the specific type within that group (like "Neutron
+ * Star" or "High metal content world").
+ */
 type SpaceBody struct {
 	ID       int
-	GroupID  int      `json:"group_id"` // 6 = star
+	Name     string   `json:"name"`
+	GroupID  int      `json:"group_id"`
 	SystemID SystemID `json:"system_id"`
 
+	GroupName     string `json:"group_name"`
+	TypeName      string `json:"type_name"`
 	SpectralClass string `json:"spectral_class"`
+	IsMainStar    bool   `json:"is_main_star"`
+
+	IsLandable        bool        `json:"is_landable"`
+	TerraformingState string      `json:"terraforming_state_name"` // like "Candidate for terraforming"
+	DistanceToArrival float64     `json:"distance_to_arrival"`     // light seconds from the arrival star
+	Rings             []SpaceRing `json:"rings"`
+}
+
+type SpaceRing struct {
+	Name        string  `json:"name"`
+	TypeName    string  `json:"ring_type_name"` // like "Icy" or "Metal Rich"
+	InnerRadius float64 `json:"ring_inner_radius"`
+	OuterRadius float64 `json:"ring_outer_radius"`
+	Mass        float64 `json:"ring_mass"`
+}
+
+/**
+ * Returns true if the body could be (or already has been) terraformed.
+ */
+func (body *SpaceBody) IsTerraformable() bool {
+	return body.TerraformingState == "Candidate for terraforming" || body.TerraformingState == "Terraformed"
+}
+
+/**
+ * Stars that can be fuel scooped, by spectral class.
+ */
+var scoopableClasses = map[string]bool{
+	"O": true, "B": true, "A": true, "F": true, "G": true, "K": true, "M": true,
+}
+
+func (body *SpaceBody) IsScoopable() bool {
+	return scoopableClasses[body.SpectralClass]
+}
+
+func (body *SpaceBody) IsNeutronStar() bool {
+	return body.SpectralClass == "N" || strings.HasPrefix(body.TypeName, "Neutron")
+}
+
+func (body *SpaceBody) IsWhiteDwarf() bool {
+	return strings.HasPrefix(body.SpectralClass, "D") || strings.HasPrefix(body.TypeName, "White Dwarf")
+}
+
+/**
+ * eddb doesn't always give black holes a spectral class, so the type name ("Black Hole" or
+ * "Supermassive Black Hole") is checked as well.
+ */
+func (body *SpaceBody) IsBlackHole() bool {
+	return body.SpectralClass == "H" || body.SpectralClass == "SupermassiveBlackHole" ||
+		strings.HasSuffix(body.TypeName, "Black Hole")
+}
+
+/**
+ * Updates everything we know about the system's arrival star. Ships drop out of hyperspace next
+ * to the arrival star, so that's the only star that matters for scooping and supercharging; other
+ * stars in the system are too far away to be worth the trip. A nil star means the arrival star
+ * isn't known, in which case we can't count on any of them.
+ */
+func (system *SpaceSystem) SetArrivalStar(star *SpaceBody) {
+	if star == nil {
+		star = &SpaceBody{}
+	}
+
+	system.ArrivalStarClass = star.SpectralClass
+	system.ContainsScoopableStar = star.IsScoopable()
+	system.ArrivalNeutronStar = star.IsNeutronStar()
+	system.ArrivalWhiteDwarf = star.IsWhiteDwarf()
+	system.ArrivalBlackHole = star.IsBlackHole()
 }
 
 type SpaceStop struct {
 	System           *SpaceSystem
 	DistanceFromPrev float64
 	RequestedStop    bool
+	Refuel           bool    // whether the ship should refuel before leaving this stop
+	FuelRemaining    float64 // tons of fuel left in the tank on arrival
+	Supercharge      bool    // whether the ship should supercharge its FSD before leaving this stop
+	Hazard           string  `json:",omitempty"` // what's dangerous about the arrival star, if anything (see ArrivalHazard)
+
+	// Stations in the system, if they've been looked up (see SpaceDB.StationsIn).
+	Stations []*SpaceStation `json:",omitempty"`
+
+	// The station the ship is heading to in this system, if it's docking (either because it was
+	// asked to or to refuel), and the seconds it takes to supercruise there.
+	Station         *SpaceStation `json:",omitempty"`
+	SupercruiseTime float64
 	// ID                    SystemID
 	// Name                  string
 	// ContainsScoopableStar bool
@@ -60,43 +189,125 @@ type SpaceStop struct {
 }
 
 type SpaceDB struct {
-	Stations []*SpaceSystem
+	Bodies   *BodyStore // nil if there's no body store; access via BodiesIn()
+	Stations []*SpaceStation
 	Systems  []*SpaceSystem
+
+	stationsByID     map[SystemID]*SpaceStation   // access via Station()
+	stationsBySystem map[SystemID][]*SpaceStation // access via StationsIn()
 }
 
 func Connect(dbPath string) *SpaceDB {
 	fmt.Println("Connecting to SpaceDB")
 	var err error
 
-	db := new(SpaceDB)
 	systems, _ := ioutil.ReadFile("data/" + dbPath + ".db")
 
 	universe := space.Universe{}
 	proto.Unmarshal(systems, &universe)
 
+	db := NewSpaceDB(&universe)
+
+	// Bodies are kept in their own file, and are only read when they're asked for.
+	if db.Bodies, err = OpenBodies("data/" + dbPath + "-bodies.db"); err != nil {
+		log.Println("Bodies won't be available:", err)
+	}
+
+	return db
+}
+
+/**
+ * Builds a SpaceDB out of the systems and stations in a universe. Bodies aren't part of the
+ * universe; they're added separately (see BodyStore).
+ */
+func NewSpaceDB(universe *space.Universe) *SpaceDB {
+	db := new(SpaceDB)
 	db.Systems = make([]*SpaceSystem, len(universe.GetSystems()))
+	systemsByID := make(map[SystemID]*SpaceSystem, len(universe.GetSystems()))
 
 	for i, sys := range universe.GetSystems() {
 		// Space is actually allocated for all systems here, and only here. Any other
 		// data structure should maintain a reference to this object.
 		db.Systems[i] = &SpaceSystem{
-			ID:   SystemID(sys.GetSystemID()),
-			Name: sys.GetName(),
-			X:    sys.GetX(),
-			Y:    sys.GetY(),
-			Z:    sys.GetZ(),
+			ID:                    SystemID(sys.GetSystemID()),
+			Name:                  sys.GetName(),
+			X:                     sys.GetX(),
+			Y:                     sys.GetY(),
+			Z:                     sys.GetZ(),
 			ContainsRefuelStation: sys.GetContainsRefuelStation(),
 			ContainsScoopableStar: sys.GetContainsScoopableStar(),
+			RefuelPadSize:         sys.GetRefuelPadSize(),
+			ArrivalStarClass:      sys.GetArrivalStarClass(),
+			ArrivalNeutronStar:    sys.GetArrivalNeutronStar(),
+			ArrivalWhiteDwarf:     sys.GetArrivalWhiteDwarf(),
+			ArrivalBlackHole:      sys.GetArrivalBlackHole(),
+			NeedsPermit:           sys.GetNeedsPermit(),
+			Security:              sys.GetSecurity(),
+			Government:            sys.GetGovernment(),
+			Allegiance:            sys.GetAllegiance(),
+			States:                sys.GetStates(),
 		}
+
+		systemsByID[db.Systems[i].ID] = db.Systems[i]
 	}
 
-	if err != nil {
-		log.Fatal(err)
+	db.Stations = make([]*SpaceStation, len(universe.GetStations()))
+	db.stationsByID = make(map[SystemID]*SpaceStation)
+	db.stationsBySystem = make(map[SystemID][]*SpaceStation)
+
+	for i, sta := range universe.GetStations() {
+		db.Stations[i] = &SpaceStation{
+			ID:                     SystemID(sta.GetStationID()),
+			Name:                   sta.GetName(),
+			SystemID:               SystemID(sta.GetSystemID()),
+			DistanceToStar:         int(sta.GetDistanceToStar()),
+			Type:                   sta.GetType(),
+			MaxLandingPad:          sta.GetMaxLandingPadSize(),
+			HasRefuel:              sta.GetHasRefuel(),
+			HasRepair:              sta.GetHasRepair(),
+			HasRearm:               sta.GetHasRearm(),
+			HasShipyard:            sta.GetHasShipyard(),
+			HasOutfitting:          sta.GetHasOutfitting(),
+			HasMaterialTrader:      sta.GetHasMaterialTrader(),
+			HasInterstellarFactors: sta.GetHasInterstellarFactors(),
+		}
+
+		db.stationsByID[db.Stations[i].ID] = db.Stations[i]
+		db.stationsBySystem[db.Stations[i].SystemID] = append(db.stationsBySystem[db.Stations[i].SystemID], db.Stations[i])
+
+		if system := systemsByID[db.Stations[i].SystemID]; system != nil && db.Stations[i].HasRefuel {
+			system.RefuelStations = append(system.RefuelStations, db.Stations[i])
+		}
 	}
 
 	return db
 }
 
+/**
+ * Returns all known bodies in the system, or nil if there aren't any (or there's no body store).
+ */
+func (db *SpaceDB) BodiesIn(id SystemID) ([]*SpaceBody, error) {
+	if db.Bodies == nil {
+		return nil, nil
+	}
+
+	return db.Bodies.BodiesIn(id)
+}
+
+/**
+ * Returns the station with the specified ID, or nil if there isn't one.
+ */
+func (db *SpaceDB) Station(id SystemID) *SpaceStation {
+	return db.stationsByID[id]
+}
+
+/**
+ * Returns all stations in the system, or nil if there aren't any.
+ */
+func (db *SpaceDB) StationsIn(id SystemID) []*SpaceStation {
+	return db.stationsBySystem[id]
+}
+
 func (db *SpaceDB) ForEachSystem(each func(*SpaceSystem)) {
 	for _, system := range db.Systems {
 		each(system)
@@ -107,8 +318,140 @@ func (src *SpaceSystem) DistanceTo(dest *SpaceSystem) float64 {
 	return math.Sqrt((dest.X-src.X)*(dest.X-src.X) + (dest.Y-src.Y)*(dest.Y-src.Y) + (dest.Z-src.Z)*(dest.Z-src.Z))
 }
 
+/**
+ * Returns false if any of the system's coordinates are NaN or infinite. Distances to systems
+ * like that are meaningless, so searches around them can't ever find anything.
+ */
+func (src *SpaceSystem) HasFiniteCoords() bool {
+	for _, coord := range [3]float64{src.X, src.Y, src.Z} {
+		if math.IsNaN(coord) || math.IsInf(coord, 0) {
+			return false
+		}
+	}
+
+	return true
+}
+
+/**
+ * Estimates how many seconds it takes to supercruise from the arrival star to the station. Ships
+ * accelerate the further they go, so the time grows roughly with the cube root of the distance;
+ * this fits commonly reported times from a few hundred Ls out to a few hundred thousand.
+ */
+func (station *SpaceStation) SupercruiseTime() float64 {
+	if station == nil || station.DistanceToStar <= 0 {
+		return 0
+	}
+
+	return 10.7 * math.Cbrt(float64(station.DistanceToStar))
+}
+
+/**
+ * Returns the station with the shortest supercruise from the arrival star out of the ones that
+ * `want` accepts, or nil if it doesn't accept any of them.
+ */
+func ClosestStation(stations []*SpaceStation, want func(*SpaceStation) bool) *SpaceStation {
+	var closest *SpaceStation
+
+	for _, station := range stations {
+		if want(station) && (closest == nil || station.DistanceToStar < closest.DistanceToStar) {
+			closest = station
+		}
+	}
+
+	return closest
+}
+
+/**
+ * Returns the station that sells fuel with the shortest supercruise from the arrival star, out of
+ * the ones with a landing pad that fits the ship. An empty pad size means any station will do.
+ */
+func (src *SpaceSystem) RefuelStation(padSize string) *SpaceStation {
+	return ClosestStation(src.RefuelStations, func(station *SpaceStation) bool {
+		return station.MaxLandingPad != "" && PadFits(station.MaxLandingPad, padSize)
+	})
+}
+
+/**
+ * Returns true if the system is currently in the named state (like "War"). Case doesn't matter.
+ */
+func (src *SpaceSystem) HasState(state string) bool {
+	for _, current := range src.States {
+		if strings.EqualFold(current, state) {
+			return true
+		}
+	}
+
+	return false
+}
+
+/**
+ * Returns true if ships are able to refuel in this system, either by scooping or by docking.
+ */
+func (src *SpaceSystem) CanRefuel() bool {
+	return src.CanRefuelWith("")
+}
+
+/**
+ * Returns true if a ship that needs the specified size of landing pad ("S", "M" or "L") is able
+ * to refuel in this system. Any ship can scoop fuel, but it can only refuel at a station if the
+ * station has a pad that's big enough. An empty pad size means any station will do.
+ */
+func (src *SpaceSystem) CanRefuelWith(padSize string) bool {
+	return src.ContainsScoopableStar || (src.ContainsRefuelStation && PadFits(src.RefuelPadSize, padSize))
+}
+
+// Landing pad sizes, smallest first.
+var padSizes = map[string]int{"S": 1, "M": 2, "L": 3}
+
+/**
+ * Returns true if a ship that needs a pad of size `needed` can land on a pad of size `pad`. Ships
+ * that don't say what they need can land anywhere.
+ */
+func PadFits(pad string, needed string) bool {
+	return needed == "" || padSizes[pad] >= padSizes[needed]
+}
+
+/**
+ * Returns the factor that an FSD supercharge multiplies jump range by when leaving this system,
+ * or 1 if the arrival star can't supercharge.
+ */
+func (src *SpaceSystem) SuperchargeFactor() float64 {
+	if src.ArrivalNeutronStar {
+		return NeutronSupercharge
+	} else if src.ArrivalWhiteDwarf {
+		return WhiteDwarfSupercharge
+	}
+
+	return 1
+}
+
+// Arrival stars that are dangerous to drop out of hyperspace next to. Pilots who aren't expecting
+// them can easily overheat, get caught in a jet cone or fly straight into the star.
+const (
+	HazardNeutronStar = "neutron_star"
+	HazardWhiteDwarf  = "white_dwarf"
+	HazardBlackHole   = "black_hole"
+)
+
+/**
+ * Returns which of the hazards the system's arrival star is, or an empty string if it's safe (or
+ * unknown).
+ */
+func (src *SpaceSystem) ArrivalHazard() string {
+	if src.ArrivalNeutronStar {
+		return HazardNeutronStar
+	} else if src.ArrivalWhiteDwarf {
+		return HazardWhiteDwarf
+	} else if src.ArrivalBlackHole {
+		return HazardBlackHole
+	}
+
+	return ""
+}
+
 func (src *SpaceSystem) AsStop() *SpaceStop {
 	return &SpaceStop{
 		System: src,
+		Hazard: src.ArrivalHazard(),
 	}
 }
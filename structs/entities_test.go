@@ -0,0 +1,199 @@
+package structs
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/anyweez/edpaths/structs/gen"
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStationsIn(t *testing.T) {
+	universe := &space.Universe{
+		Systems: []*space.SpaceSystem{
+			testSystem(1, "Busy", 0),
+			testSystem(2, "Empty", 5),
+		},
+		Stations: []*space.SpaceStation{
+			{
+				StationID:         proto.Int32(10),
+				Name:              proto.String("Starport"),
+				SystemID:          proto.Int32(1),
+				DistanceToStar:    proto.Int32(320),
+				Type:              proto.String("Coriolis Starport"),
+				MaxLandingPadSize: proto.String("L"),
+				HasRefuel:         proto.Bool(true),
+				HasShipyard:       proto.Bool(true),
+			},
+			{StationID: proto.Int32(11), Name: proto.String("Outpost"), SystemID: proto.Int32(1), MaxLandingPadSize: proto.String("M")},
+		},
+	}
+
+	// Make sure stations survive being written out and read back in.
+	raw, err := proto.Marshal(universe)
+	assert.Nil(t, err)
+
+	loaded := space.Universe{}
+	assert.Nil(t, proto.Unmarshal(raw, &loaded))
+
+	db := NewSpaceDB(&loaded)
+
+	stations := db.StationsIn(1)
+	if assert.Equal(t, 2, len(stations)) {
+		assert.Equal(t, SpaceStation{
+			ID:             10,
+			Name:           "Starport",
+			SystemID:       1,
+			DistanceToStar: 320,
+			Type:           "Coriolis Starport",
+			MaxLandingPad:  "L",
+			HasRefuel:      true,
+			HasShipyard:    true,
+		}, *stations[0])
+		assert.Equal(t, "Outpost", stations[1].Name)
+	}
+
+	assert.Equal(t, 0, len(db.StationsIn(2)))
+	assert.Equal(t, 2, len(db.Systems))
+}
+
+func TestBodiesIn(t *testing.T) {
+	records := []*space.SpaceBody{
+		{
+			BodyID:     proto.Int32(100),
+			Name:       proto.String("Ringed A"),
+			SystemID:   proto.Int32(1),
+			Group:      proto.String("Star"),
+			Type:       proto.String("K (Yellow-Orange) Star"),
+			IsMainStar: proto.Bool(true),
+		},
+		{
+			BodyID:            proto.Int32(101),
+			Name:              proto.String("Ringed A 1"),
+			SystemID:          proto.Int32(1),
+			Group:             proto.String("Planet"),
+			Type:              proto.String("High metal content world"),
+			IsLandable:        proto.Bool(true),
+			TerraformingState: proto.String("Candidate for terraforming"),
+			DistanceToArrival: proto.Float64(812.5),
+			Rings: []*space.SpaceRing{
+				{Name: proto.String("Ringed A 1 A Ring"), Type: proto.String("Metal Rich"), InnerRadius: proto.Float64(1000), OuterRadius: proto.Float64(2500)},
+			},
+		},
+		{
+			BodyID:   proto.Int32(200),
+			Name:     proto.String("Elsewhere A"),
+			SystemID: proto.Int32(2),
+		},
+	}
+
+	// Bodies from different systems can be mixed together in the store.
+	var raw bytes.Buffer
+	out := NewBodyWriter(&raw)
+	for _, i := range []int{0, 2, 1} {
+		assert.Nil(t, out.Write(records[i]))
+	}
+	assert.Nil(t, out.Flush())
+
+	store, err := NewBodyStore(bytes.NewReader(raw.Bytes()))
+	assert.Nil(t, err)
+
+	db := NewSpaceDB(&space.Universe{Systems: []*space.SpaceSystem{testSystem(1, "Ringed", 0)}})
+	db.Bodies = store
+
+	bodies, err := db.BodiesIn(1)
+	assert.Nil(t, err)
+	if assert.Equal(t, 2, len(bodies)) {
+		assert.True(t, bodies[0].IsMainStar)
+		assert.Equal(t, "Star", bodies[0].GroupName)
+
+		assert.Equal(t, "High metal content world", bodies[1].TypeName)
+		assert.True(t, bodies[1].IsLandable)
+		assert.True(t, bodies[1].IsTerraformable())
+		assert.Equal(t, 812.5, bodies[1].DistanceToArrival)
+		assert.Equal(t, []SpaceRing{{Name: "Ringed A 1 A Ring", TypeName: "Metal Rich", InnerRadius: 1000, OuterRadius: 2500}}, bodies[1].Rings)
+	}
+
+	bodies, err = db.BodiesIn(3)
+	assert.Nil(t, err)
+	assert.Nil(t, bodies)
+
+	// A store that's been cut off part way through a record can't be trusted.
+	_, err = NewBodyStore(bytes.NewReader(raw.Bytes()[:raw.Len()-1]))
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+}
+
+func TestCanRefuelWith(t *testing.T) {
+	assert.True(t, PadFits("L", "M"))
+	assert.True(t, PadFits("M", "M"))
+	assert.False(t, PadFits("S", "M"))
+	assert.True(t, PadFits("S", ""))
+
+	outpost := &SpaceSystem{ContainsRefuelStation: true, RefuelPadSize: "M"}
+	assert.True(t, outpost.CanRefuel())
+	assert.True(t, outpost.CanRefuelWith("S"))
+	assert.False(t, outpost.CanRefuelWith("L"))
+
+	scoop := &SpaceSystem{ContainsScoopableStar: true}
+	assert.True(t, scoop.CanRefuelWith("L"), "any ship can scoop")
+}
+
+func TestSupercruise(t *testing.T) {
+	near := &SpaceStation{ID: 1, DistanceToStar: 50, HasRefuel: true}
+	far := &SpaceStation{ID: 2, DistanceToStar: 300000, HasRefuel: true}
+	dry := &SpaceStation{ID: 3, DistanceToStar: 10}
+
+	assert.True(t, near.SupercruiseTime() < far.SupercruiseTime())
+	assert.InDelta(t, 107, (&SpaceStation{DistanceToStar: 1000}).SupercruiseTime(), 0.001)
+	assert.Equal(t, 0.0, (*SpaceStation)(nil).SupercruiseTime())
+
+	refuels := func(station *SpaceStation) bool { return station.HasRefuel }
+	assert.Equal(t, near, ClosestStation([]*SpaceStation{far, dry, near}, refuels))
+	assert.Nil(t, ClosestStation([]*SpaceStation{dry}, refuels))
+
+	// A minute and a half of supercruise is worth two jumps.
+	assert.InDelta(t, 20, (&RoutingConstraints{MaxJump: 10}).TimeCost(2*JumpTime), 0.001)
+	assert.InDelta(t, 2, (&RoutingConstraints{MaxJump: 10, Supercharge: true}).TimeCost(2*JumpTime), 0.001)
+}
+
+func testSystem(id int32, name string, x float64) *space.SpaceSystem {
+	return &space.SpaceSystem{
+		SystemID:              proto.Int32(id),
+		Name:                  proto.String(name),
+		X:                     proto.Float64(x),
+		Y:                     proto.Float64(0),
+		Z:                     proto.Float64(0),
+		ContainsScoopableStar: proto.Bool(false),
+		ContainsRefuelStation: proto.Bool(false),
+	}
+}
+
+/**
+ * A short line of systems with one way around the step, but none around the chokepoint. With
+ * 5.5 LY jumps the best route from 1 to 5 is 1, 2, 4, 5, and going through the detour instead
+ * is 2 LY longer.
+ */
+func chokepointSystems() []*SpaceSystem {
+	return []*SpaceSystem{
+		{ID: 1, Name: "Origin", X: 0, Y: 0, Z: 0},
+		{ID: 2, Name: "Step", X: 4, Y: 0, Z: 0},
+		{ID: 3, Name: "Detour", X: 4, Y: 3, Z: 0},
+		{ID: 4, Name: "Chokepoint", X: 8, Y: 0, Z: 0},
+		{ID: 5, Name: "Destination", X: 12, Y: 0, Z: 0},
+	}
+}
+
+/**
+ * Builds a graph out of chokepointSystems. Anything but the positions can be changed on the
+ * systems afterwards.
+ */
+func chokepointGraph() *SpaceGraph {
+	graph := InitGraph(1000)
+	for _, system := range chokepointSystems() {
+		graph.Add(system)
+	}
+
+	return graph
+}
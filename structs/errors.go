@@ -0,0 +1,88 @@
+package structs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+/**
+ * RoutingError describes why a route couldn't be found. Code is a short, stable identifier
+ * that's safe to pass along to API clients.
+ */
+type RoutingError struct {
+	Code    string
+	Message string
+}
+
+func (err *RoutingError) Error() string {
+	return err.Message
+}
+
+var (
+	ErrUnknownSystem   = &RoutingError{Code: "unknown_system", Message: "unknown system"}
+	ErrUnknownStation  = &RoutingError{Code: "unknown_station", Message: "unknown station"}
+	ErrOutOfBounds     = &RoutingError{Code: "out_of_bounds", Message: "system is outside of the supported universe bounds"}
+	ErrUnreachable     = &RoutingError{Code: "unreachable", Message: "destination is unreachable within the jump range"}
+	ErrOutOfFuel       = &RoutingError{Code: "out_of_fuel", Message: "destination is unreachable without running out of fuel"}
+	ErrHopLimit        = &RoutingError{Code: "hop_limit", Message: "destination is unreachable within the hop limit"}
+	ErrBudgetExhausted = &RoutingError{Code: "budget_exhausted", Message: "search budget exhausted before finding a route"}
+	ErrAvoided         = &RoutingError{Code: "avoided", Message: "destination is only reachable through avoided systems"}
+	ErrFiltered        = &RoutingError{Code: "filtered", Message: "destination is only reachable through jumps that the custom filter doesn't allow"}
+	ErrPermitRequired  = &RoutingError{Code: "permit_required", Message: "destination is only reachable with a permit that isn't held"}
+)
+
+// Codes that ErrorCode returns for errors that aren't RoutingErrors.
+const (
+	CodeTimedOut  = "timed_out"
+	CodeCancelled = "cancelled"
+	CodeInternal  = "internal"
+)
+
+/**
+ * LegError is returned when there's no route between a specific pair of systems.
+ */
+type LegError struct {
+	From SystemID
+	To   SystemID
+	Err  error
+}
+
+func (err *LegError) Error() string {
+	return fmt.Sprintf("no route from %d to %d: %s", err.From, err.To, err.Err.Error())
+}
+
+func (err *LegError) Unwrap() error {
+	return err.Err
+}
+
+func legError(from *SpaceSystem, to *SpaceSystem, err error) *LegError {
+	leg := &LegError{Err: err}
+	if from != nil {
+		leg.From = from.ID
+	}
+
+	if to != nil {
+		leg.To = to.ID
+	}
+
+	return leg
+}
+
+/**
+ * Returns the code that identifies an error returned from the routing functions, or CodeInternal
+ * for errors that don't come from routing.
+ */
+func ErrorCode(err error) string {
+	var routing *RoutingError
+
+	if errors.As(err, &routing) {
+		return routing.Code
+	} else if errors.Is(err, context.DeadlineExceeded) {
+		return CodeTimedOut
+	} else if errors.Is(err, context.Canceled) {
+		return CodeCancelled
+	}
+
+	return CodeInternal
+}
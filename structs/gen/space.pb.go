@@ -37,6 +37,16 @@ type SpaceSystem struct {
 	Z                     *float64 `protobuf:"fixed64,5,req,name=Z" json:"Z,omitempty"`
 	ContainsScoopableStar *bool    `protobuf:"varint,6,req,name=ContainsScoopableStar,def=0" json:"ContainsScoopableStar,omitempty"`
 	ContainsRefuelStation *bool    `protobuf:"varint,7,req,name=ContainsRefuelStation,def=0" json:"ContainsRefuelStation,omitempty"`
+	ArrivalNeutronStar    *bool    `protobuf:"varint,8,opt,name=ArrivalNeutronStar,def=0" json:"ArrivalNeutronStar,omitempty"`
+	ArrivalWhiteDwarf     *bool    `protobuf:"varint,9,opt,name=ArrivalWhiteDwarf,def=0" json:"ArrivalWhiteDwarf,omitempty"`
+	NeedsPermit           *bool    `protobuf:"varint,10,opt,name=NeedsPermit,def=0" json:"NeedsPermit,omitempty"`
+	Security              *string  `protobuf:"bytes,11,opt,name=Security" json:"Security,omitempty"`
+	Government            *string  `protobuf:"bytes,12,opt,name=Government" json:"Government,omitempty"`
+	Allegiance            *string  `protobuf:"bytes,13,opt,name=Allegiance" json:"Allegiance,omitempty"`
+	States                []string `protobuf:"bytes,14,rep,name=States" json:"States,omitempty"`
+	RefuelPadSize         *string  `protobuf:"bytes,15,opt,name=RefuelPadSize" json:"RefuelPadSize,omitempty"`
+	ArrivalStarClass      *string  `protobuf:"bytes,16,opt,name=ArrivalStarClass" json:"ArrivalStarClass,omitempty"`
+	ArrivalBlackHole      *bool    `protobuf:"varint,17,opt,name=ArrivalBlackHole,def=0" json:"ArrivalBlackHole,omitempty"`
 	XXX_unrecognized      []byte   `json:"-"`
 }
 
@@ -47,6 +57,10 @@ func (*SpaceSystem) Descriptor() ([]byte, []int) { return fileDescriptor0, []int
 
 const Default_SpaceSystem_ContainsScoopableStar bool = false
 const Default_SpaceSystem_ContainsRefuelStation bool = false
+const Default_SpaceSystem_ArrivalNeutronStar bool = false
+const Default_SpaceSystem_ArrivalWhiteDwarf bool = false
+const Default_SpaceSystem_NeedsPermit bool = false
+const Default_SpaceSystem_ArrivalBlackHole bool = false
 
 func (m *SpaceSystem) GetSystemID() int32 {
 	if m != nil && m.SystemID != nil {
@@ -97,9 +111,80 @@ func (m *SpaceSystem) GetContainsRefuelStation() bool {
 	return Default_SpaceSystem_ContainsRefuelStation
 }
 
+func (m *SpaceSystem) GetArrivalNeutronStar() bool {
+	if m != nil && m.ArrivalNeutronStar != nil {
+		return *m.ArrivalNeutronStar
+	}
+	return Default_SpaceSystem_ArrivalNeutronStar
+}
+
+func (m *SpaceSystem) GetArrivalWhiteDwarf() bool {
+	if m != nil && m.ArrivalWhiteDwarf != nil {
+		return *m.ArrivalWhiteDwarf
+	}
+	return Default_SpaceSystem_ArrivalWhiteDwarf
+}
+
+func (m *SpaceSystem) GetNeedsPermit() bool {
+	if m != nil && m.NeedsPermit != nil {
+		return *m.NeedsPermit
+	}
+	return Default_SpaceSystem_NeedsPermit
+}
+
+func (m *SpaceSystem) GetSecurity() string {
+	if m != nil && m.Security != nil {
+		return *m.Security
+	}
+	return ""
+}
+
+func (m *SpaceSystem) GetGovernment() string {
+	if m != nil && m.Government != nil {
+		return *m.Government
+	}
+	return ""
+}
+
+func (m *SpaceSystem) GetAllegiance() string {
+	if m != nil && m.Allegiance != nil {
+		return *m.Allegiance
+	}
+	return ""
+}
+
+func (m *SpaceSystem) GetStates() []string {
+	if m != nil {
+		return m.States
+	}
+	return nil
+}
+
+func (m *SpaceSystem) GetRefuelPadSize() string {
+	if m != nil && m.RefuelPadSize != nil {
+		return *m.RefuelPadSize
+	}
+	return ""
+}
+
+func (m *SpaceSystem) GetArrivalStarClass() string {
+	if m != nil && m.ArrivalStarClass != nil {
+		return *m.ArrivalStarClass
+	}
+	return ""
+}
+
+func (m *SpaceSystem) GetArrivalBlackHole() bool {
+	if m != nil && m.ArrivalBlackHole != nil {
+		return *m.ArrivalBlackHole
+	}
+	return Default_SpaceSystem_ArrivalBlackHole
+}
+
 type Universe struct {
-	Systems          []*SpaceSystem `protobuf:"bytes,1,rep,name=systems" json:"systems,omitempty"`
-	XXX_unrecognized []byte         `json:"-"`
+	Systems          []*SpaceSystem  `protobuf:"bytes,1,rep,name=systems" json:"systems,omitempty"`
+	Stations         []*SpaceStation `protobuf:"bytes,2,rep,name=stations" json:"stations,omitempty"`
+	XXX_unrecognized []byte          `json:"-"`
 }
 
 func (m *Universe) Reset()                    { *m = Universe{} }
@@ -114,26 +199,341 @@ func (m *Universe) GetSystems() []*SpaceSystem {
 	return nil
 }
 
+func (m *Universe) GetStations() []*SpaceStation {
+	if m != nil {
+		return m.Stations
+	}
+	return nil
+}
+
+type SpaceStation struct {
+	StationID              *int32  `protobuf:"varint,1,req,name=StationID" json:"StationID,omitempty"`
+	Name                   *string `protobuf:"bytes,2,req,name=Name" json:"Name,omitempty"`
+	SystemID               *int32  `protobuf:"varint,3,req,name=SystemID" json:"SystemID,omitempty"`
+	DistanceToStar         *int32  `protobuf:"varint,4,opt,name=DistanceToStar" json:"DistanceToStar,omitempty"`
+	Type                   *string `protobuf:"bytes,5,opt,name=Type" json:"Type,omitempty"`
+	MaxLandingPadSize      *string `protobuf:"bytes,6,opt,name=MaxLandingPadSize" json:"MaxLandingPadSize,omitempty"`
+	HasRefuel              *bool   `protobuf:"varint,7,opt,name=HasRefuel,def=0" json:"HasRefuel,omitempty"`
+	HasRepair              *bool   `protobuf:"varint,8,opt,name=HasRepair,def=0" json:"HasRepair,omitempty"`
+	HasRearm               *bool   `protobuf:"varint,9,opt,name=HasRearm,def=0" json:"HasRearm,omitempty"`
+	HasShipyard            *bool   `protobuf:"varint,10,opt,name=HasShipyard,def=0" json:"HasShipyard,omitempty"`
+	HasOutfitting          *bool   `protobuf:"varint,11,opt,name=HasOutfitting,def=0" json:"HasOutfitting,omitempty"`
+	HasMaterialTrader      *bool   `protobuf:"varint,12,opt,name=HasMaterialTrader,def=0" json:"HasMaterialTrader,omitempty"`
+	HasInterstellarFactors *bool   `protobuf:"varint,13,opt,name=HasInterstellarFactors,def=0" json:"HasInterstellarFactors,omitempty"`
+	XXX_unrecognized       []byte  `json:"-"`
+}
+
+func (m *SpaceStation) Reset()                    { *m = SpaceStation{} }
+func (m *SpaceStation) String() string            { return proto.CompactTextString(m) }
+func (*SpaceStation) ProtoMessage()               {}
+func (*SpaceStation) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{2} }
+
+const Default_SpaceStation_HasRefuel bool = false
+const Default_SpaceStation_HasRepair bool = false
+const Default_SpaceStation_HasRearm bool = false
+const Default_SpaceStation_HasShipyard bool = false
+const Default_SpaceStation_HasOutfitting bool = false
+const Default_SpaceStation_HasMaterialTrader bool = false
+const Default_SpaceStation_HasInterstellarFactors bool = false
+
+func (m *SpaceStation) GetStationID() int32 {
+	if m != nil && m.StationID != nil {
+		return *m.StationID
+	}
+	return 0
+}
+
+func (m *SpaceStation) GetName() string {
+	if m != nil && m.Name != nil {
+		return *m.Name
+	}
+	return ""
+}
+
+func (m *SpaceStation) GetSystemID() int32 {
+	if m != nil && m.SystemID != nil {
+		return *m.SystemID
+	}
+	return 0
+}
+
+func (m *SpaceStation) GetDistanceToStar() int32 {
+	if m != nil && m.DistanceToStar != nil {
+		return *m.DistanceToStar
+	}
+	return 0
+}
+
+func (m *SpaceStation) GetType() string {
+	if m != nil && m.Type != nil {
+		return *m.Type
+	}
+	return ""
+}
+
+func (m *SpaceStation) GetMaxLandingPadSize() string {
+	if m != nil && m.MaxLandingPadSize != nil {
+		return *m.MaxLandingPadSize
+	}
+	return ""
+}
+
+func (m *SpaceStation) GetHasRefuel() bool {
+	if m != nil && m.HasRefuel != nil {
+		return *m.HasRefuel
+	}
+	return Default_SpaceStation_HasRefuel
+}
+
+func (m *SpaceStation) GetHasRepair() bool {
+	if m != nil && m.HasRepair != nil {
+		return *m.HasRepair
+	}
+	return Default_SpaceStation_HasRepair
+}
+
+func (m *SpaceStation) GetHasRearm() bool {
+	if m != nil && m.HasRearm != nil {
+		return *m.HasRearm
+	}
+	return Default_SpaceStation_HasRearm
+}
+
+func (m *SpaceStation) GetHasShipyard() bool {
+	if m != nil && m.HasShipyard != nil {
+		return *m.HasShipyard
+	}
+	return Default_SpaceStation_HasShipyard
+}
+
+func (m *SpaceStation) GetHasOutfitting() bool {
+	if m != nil && m.HasOutfitting != nil {
+		return *m.HasOutfitting
+	}
+	return Default_SpaceStation_HasOutfitting
+}
+
+func (m *SpaceStation) GetHasMaterialTrader() bool {
+	if m != nil && m.HasMaterialTrader != nil {
+		return *m.HasMaterialTrader
+	}
+	return Default_SpaceStation_HasMaterialTrader
+}
+
+func (m *SpaceStation) GetHasInterstellarFactors() bool {
+	if m != nil && m.HasInterstellarFactors != nil {
+		return *m.HasInterstellarFactors
+	}
+	return Default_SpaceStation_HasInterstellarFactors
+}
+
+type SpaceBody struct {
+	BodyID            *int32       `protobuf:"varint,1,req,name=BodyID" json:"BodyID,omitempty"`
+	Name              *string      `protobuf:"bytes,2,req,name=Name" json:"Name,omitempty"`
+	SystemID          *int32       `protobuf:"varint,3,req,name=SystemID" json:"SystemID,omitempty"`
+	Group             *string      `protobuf:"bytes,4,opt,name=Group" json:"Group,omitempty"`
+	Type              *string      `protobuf:"bytes,5,opt,name=Type" json:"Type,omitempty"`
+	SpectralClass     *string      `protobuf:"bytes,6,opt,name=SpectralClass" json:"SpectralClass,omitempty"`
+	IsMainStar        *bool        `protobuf:"varint,7,opt,name=IsMainStar,def=0" json:"IsMainStar,omitempty"`
+	IsLandable        *bool        `protobuf:"varint,8,opt,name=IsLandable,def=0" json:"IsLandable,omitempty"`
+	TerraformingState *string      `protobuf:"bytes,9,opt,name=TerraformingState" json:"TerraformingState,omitempty"`
+	DistanceToArrival *float64     `protobuf:"fixed64,10,opt,name=DistanceToArrival" json:"DistanceToArrival,omitempty"`
+	Rings             []*SpaceRing `protobuf:"bytes,11,rep,name=rings" json:"rings,omitempty"`
+	XXX_unrecognized  []byte       `json:"-"`
+}
+
+func (m *SpaceBody) Reset()                    { *m = SpaceBody{} }
+func (m *SpaceBody) String() string            { return proto.CompactTextString(m) }
+func (*SpaceBody) ProtoMessage()               {}
+func (*SpaceBody) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{3} }
+
+const Default_SpaceBody_IsMainStar bool = false
+const Default_SpaceBody_IsLandable bool = false
+
+func (m *SpaceBody) GetBodyID() int32 {
+	if m != nil && m.BodyID != nil {
+		return *m.BodyID
+	}
+	return 0
+}
+
+func (m *SpaceBody) GetName() string {
+	if m != nil && m.Name != nil {
+		return *m.Name
+	}
+	return ""
+}
+
+func (m *SpaceBody) GetSystemID() int32 {
+	if m != nil && m.SystemID != nil {
+		return *m.SystemID
+	}
+	return 0
+}
+
+func (m *SpaceBody) GetGroup() string {
+	if m != nil && m.Group != nil {
+		return *m.Group
+	}
+	return ""
+}
+
+func (m *SpaceBody) GetType() string {
+	if m != nil && m.Type != nil {
+		return *m.Type
+	}
+	return ""
+}
+
+func (m *SpaceBody) GetSpectralClass() string {
+	if m != nil && m.SpectralClass != nil {
+		return *m.SpectralClass
+	}
+	return ""
+}
+
+func (m *SpaceBody) GetIsMainStar() bool {
+	if m != nil && m.IsMainStar != nil {
+		return *m.IsMainStar
+	}
+	return Default_SpaceBody_IsMainStar
+}
+
+func (m *SpaceBody) GetIsLandable() bool {
+	if m != nil && m.IsLandable != nil {
+		return *m.IsLandable
+	}
+	return Default_SpaceBody_IsLandable
+}
+
+func (m *SpaceBody) GetTerraformingState() string {
+	if m != nil && m.TerraformingState != nil {
+		return *m.TerraformingState
+	}
+	return ""
+}
+
+func (m *SpaceBody) GetDistanceToArrival() float64 {
+	if m != nil && m.DistanceToArrival != nil {
+		return *m.DistanceToArrival
+	}
+	return 0
+}
+
+func (m *SpaceBody) GetRings() []*SpaceRing {
+	if m != nil {
+		return m.Rings
+	}
+	return nil
+}
+
+type SpaceRing struct {
+	Name             *string  `protobuf:"bytes,1,req,name=Name" json:"Name,omitempty"`
+	Type             *string  `protobuf:"bytes,2,opt,name=Type" json:"Type,omitempty"`
+	InnerRadius      *float64 `protobuf:"fixed64,3,opt,name=InnerRadius" json:"InnerRadius,omitempty"`
+	OuterRadius      *float64 `protobuf:"fixed64,4,opt,name=OuterRadius" json:"OuterRadius,omitempty"`
+	Mass             *float64 `protobuf:"fixed64,5,opt,name=Mass" json:"Mass,omitempty"`
+	XXX_unrecognized []byte   `json:"-"`
+}
+
+func (m *SpaceRing) Reset()                    { *m = SpaceRing{} }
+func (m *SpaceRing) String() string            { return proto.CompactTextString(m) }
+func (*SpaceRing) ProtoMessage()               {}
+func (*SpaceRing) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{4} }
+
+func (m *SpaceRing) GetName() string {
+	if m != nil && m.Name != nil {
+		return *m.Name
+	}
+	return ""
+}
+
+func (m *SpaceRing) GetType() string {
+	if m != nil && m.Type != nil {
+		return *m.Type
+	}
+	return ""
+}
+
+func (m *SpaceRing) GetInnerRadius() float64 {
+	if m != nil && m.InnerRadius != nil {
+		return *m.InnerRadius
+	}
+	return 0
+}
+
+func (m *SpaceRing) GetOuterRadius() float64 {
+	if m != nil && m.OuterRadius != nil {
+		return *m.OuterRadius
+	}
+	return 0
+}
+
+func (m *SpaceRing) GetMass() float64 {
+	if m != nil && m.Mass != nil {
+		return *m.Mass
+	}
+	return 0
+}
+
 func init() {
 	proto.RegisterType((*SpaceSystem)(nil), "space.SpaceSystem")
 	proto.RegisterType((*Universe)(nil), "space.Universe")
+	proto.RegisterType((*SpaceStation)(nil), "space.SpaceStation")
+	proto.RegisterType((*SpaceBody)(nil), "space.SpaceBody")
+	proto.RegisterType((*SpaceRing)(nil), "space.SpaceRing")
 }
 
 func init() { proto.RegisterFile("space.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 195 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0xe2, 0xe2, 0x2e, 0x2e, 0x48, 0x4c,
-	0x4e, 0xd5, 0x2b, 0x28, 0xca, 0x2f, 0xc9, 0x17, 0x62, 0x05, 0x73, 0x94, 0x66, 0x31, 0x72, 0x71,
-	0x07, 0x83, 0x58, 0xc1, 0x95, 0xc5, 0x25, 0xa9, 0xb9, 0x42, 0x02, 0x5c, 0x1c, 0x10, 0x96, 0xa7,
-	0x8b, 0x04, 0xa3, 0x02, 0x93, 0x06, 0xab, 0x10, 0x0f, 0x17, 0x8b, 0x5f, 0x62, 0x6e, 0xaa, 0x04,
-	0x93, 0x02, 0x93, 0x06, 0xa7, 0x10, 0x27, 0x17, 0x63, 0x84, 0x04, 0xb3, 0x02, 0x93, 0x06, 0x23,
-	0x88, 0x19, 0x29, 0xc1, 0x02, 0x63, 0x46, 0x49, 0xb0, 0x82, 0x99, 0x2a, 0x5c, 0xa2, 0xce, 0xf9,
-	0x79, 0x25, 0x89, 0x99, 0x79, 0xc5, 0xc1, 0xc9, 0xf9, 0xf9, 0x05, 0x89, 0x49, 0x39, 0xa9, 0xc1,
-	0x25, 0x89, 0x45, 0x12, 0x6c, 0x0a, 0x4c, 0x1a, 0x1c, 0x56, 0xac, 0x69, 0x89, 0x39, 0xc5, 0xa9,
-	0xc8, 0xaa, 0x82, 0x52, 0xd3, 0x4a, 0x53, 0x73, 0x82, 0x4b, 0x12, 0x4b, 0x32, 0xf3, 0xf3, 0x24,
-	0xd8, 0x91, 0x54, 0x29, 0xe9, 0x73, 0x71, 0x84, 0xe6, 0x65, 0x96, 0xa5, 0x16, 0x15, 0xa7, 0x0a,
-	0x29, 0x73, 0xb1, 0x17, 0x83, 0x1d, 0x56, 0x2c, 0xc1, 0xa8, 0xc0, 0xac, 0xc1, 0x6d, 0x24, 0xa4,
-	0x07, 0xf1, 0x0e, 0x92, 0xeb, 0x01, 0x01, 0x00, 0x00, 0xff, 0xff, 0x2c, 0x26, 0x8f, 0x3b, 0xe2,
-	0x00, 0x00, 0x00,
+	// 762 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x94, 0x54, 0xc1, 0x8e, 0xe3, 0x44,
+	0x10, 0x95, 0x9d, 0x78, 0x26, 0x2e, 0x27, 0x4b, 0xa6, 0x81, 0x55, 0x0b, 0x21, 0x64, 0xc2, 0xb2,
+	0x58, 0xb0, 0x5a, 0x04, 0x88, 0x0b, 0x88, 0xc3, 0xee, 0x8e, 0xd8, 0x04, 0x91, 0xd9, 0x55, 0x3b,
+	0x88, 0x9d, 0xb9, 0x15, 0x49, 0x27, 0xd3, 0xc2, 0x69, 0x5b, 0xdd, 0x9d, 0x81, 0xf0, 0x0b, 0x7c,
+	0x2c, 0x27, 0x8e, 0x48, 0xa8, 0xdb, 0x19, 0xa7, 0x9d, 0x84, 0x03, 0xa7, 0xa9, 0x7a, 0xef, 0x95,
+	0x27, 0xae, 0xf7, 0xca, 0x90, 0xe8, 0x0a, 0xe7, 0xfc, 0x69, 0xa5, 0x4a, 0x53, 0x92, 0xc8, 0x35,
+	0xa3, 0xbf, 0xba, 0x90, 0xe4, 0xb6, 0xca, 0xb7, 0xda, 0xf0, 0x35, 0x79, 0x0f, 0x7a, 0x75, 0x35,
+	0xb9, 0xa4, 0x41, 0x1a, 0x66, 0x11, 0x6b, 0x7a, 0x42, 0xa0, 0x7b, 0x85, 0x6b, 0x4e, 0xc3, 0x34,
+	0xcc, 0x62, 0xe6, 0x6a, 0xd2, 0x87, 0xe0, 0x0d, 0xed, 0xa4, 0x61, 0x16, 0xb0, 0xe0, 0x8d, 0xed,
+	0xae, 0x69, 0xb7, 0xee, 0xae, 0x6d, 0x77, 0x43, 0xa3, 0xba, 0xbb, 0x21, 0xdf, 0xc2, 0xbb, 0x2f,
+	0x4a, 0x69, 0x50, 0x48, 0x9d, 0xcf, 0xcb, 0xb2, 0xc2, 0x5f, 0x0a, 0x9e, 0x1b, 0x54, 0xf4, 0x2c,
+	0x0d, 0xb3, 0xde, 0x37, 0xd1, 0x12, 0x0b, 0xcd, 0xd9, 0x69, 0x8d, 0x3f, 0xcc, 0xf8, 0x72, 0xc3,
+	0x8b, 0xdc, 0xa0, 0x11, 0xa5, 0xa4, 0xe7, 0x27, 0x87, 0x5b, 0x1a, 0xf2, 0x35, 0x90, 0x67, 0x4a,
+	0x89, 0x3b, 0x2c, 0xae, 0xf8, 0xc6, 0xa8, 0x52, 0xba, 0x7f, 0xdb, 0x4b, 0x83, 0xfd, 0xe4, 0x09,
+	0x01, 0xf9, 0x0a, 0x2e, 0x76, 0xe8, 0xcf, 0xb7, 0xc2, 0xf0, 0xcb, 0xdf, 0x50, 0x2d, 0x69, 0xec,
+	0x4f, 0x1d, 0xf3, 0xe4, 0x13, 0x48, 0xae, 0x38, 0x5f, 0xe8, 0xd7, 0x5c, 0xad, 0x85, 0xa1, 0xe0,
+	0xcb, 0x7d, 0xc6, 0x2d, 0x9a, 0xcf, 0x37, 0x4a, 0x98, 0x2d, 0x4d, 0xd2, 0x20, 0x8b, 0x59, 0xd3,
+	0x93, 0x0f, 0x00, 0x5e, 0x96, 0x77, 0x5c, 0xc9, 0x35, 0x97, 0x86, 0xf6, 0x1d, 0xeb, 0x21, 0x96,
+	0x7f, 0x56, 0x14, 0x7c, 0x25, 0x50, 0xce, 0x39, 0x1d, 0xd4, 0xfc, 0x1e, 0x21, 0x0f, 0xe1, 0xcc,
+	0xbe, 0x3b, 0xd7, 0xf4, 0x41, 0xda, 0xc9, 0x62, 0xb6, 0xeb, 0xc8, 0x23, 0x18, 0xd4, 0x9b, 0x79,
+	0x8d, 0x8b, 0x5c, 0xfc, 0xc1, 0xe9, 0x5b, 0x6e, 0xb4, 0x0d, 0x92, 0x4f, 0x61, 0xb8, 0x7b, 0x2f,
+	0xbb, 0x86, 0x17, 0x05, 0x6a, 0x4d, 0x87, 0x4e, 0x78, 0x84, 0x93, 0x2f, 0x1a, 0xed, 0xf3, 0x02,
+	0xe7, 0xbf, 0x8e, 0xcb, 0x82, 0xd3, 0x0b, 0xff, 0x9d, 0x8f, 0xe8, 0x51, 0x09, 0xbd, 0x9f, 0xa4,
+	0xb8, 0xe3, 0x4a, 0x73, 0xf2, 0x04, 0xce, 0xb5, 0x4b, 0x97, 0xa6, 0x41, 0xda, 0xc9, 0x92, 0x2f,
+	0xc9, 0xd3, 0x3a, 0xa3, 0x5e, 0x24, 0xd9, 0xbd, 0x84, 0x7c, 0x0e, 0x3d, 0x5d, 0x5b, 0xaa, 0x69,
+	0xe8, 0xe4, 0x6f, 0xb7, 0xe4, 0x35, 0xc7, 0x1a, 0xd1, 0x0f, 0xdd, 0x5e, 0x67, 0xd8, 0x1d, 0xfd,
+	0xd3, 0x81, 0xbe, 0x2f, 0x20, 0xef, 0x43, 0xbc, 0x2b, 0x9b, 0x90, 0xef, 0x81, 0x93, 0x29, 0xf7,
+	0xaf, 0xa2, 0x73, 0x70, 0x15, 0x8f, 0xe1, 0xc1, 0xa5, 0xd0, 0xc6, 0x2e, 0x7e, 0x56, 0xba, 0x64,
+	0x75, 0xd3, 0x20, 0x8b, 0xd8, 0x01, 0x6a, 0x9f, 0x3b, 0xdb, 0x56, 0x9c, 0x46, 0x6e, 0x95, 0xae,
+	0x26, 0x4f, 0xe0, 0x62, 0x8a, 0xbf, 0xff, 0x88, 0x72, 0x21, 0xe4, 0xea, 0xde, 0x94, 0x33, 0x27,
+	0x38, 0x26, 0xc8, 0x47, 0x10, 0x8f, 0x71, 0x97, 0x6d, 0x7a, 0xee, 0x6f, 0x79, 0x8f, 0x37, 0xa2,
+	0x0a, 0xc5, 0x41, 0xc6, 0xf7, 0x38, 0xf9, 0x10, 0x7a, 0xae, 0x41, 0xb5, 0x6e, 0x27, 0xba, 0x81,
+	0x6d, 0x90, 0xc7, 0xa8, 0xf3, 0x5b, 0x51, 0x6d, 0x51, 0x2d, 0x0e, 0x82, 0xec, 0x31, 0xe4, 0x33,
+	0x18, 0x8c, 0x51, 0xbf, 0xda, 0x98, 0xa5, 0x30, 0x46, 0xc8, 0x15, 0x4d, 0x7c, 0x69, 0x9b, 0xb3,
+	0x37, 0x35, 0x46, 0x3d, 0x45, 0xc3, 0x95, 0xc0, 0x62, 0xa6, 0x70, 0xc1, 0x15, 0xed, 0xfb, 0x03,
+	0xc7, 0x3c, 0xf9, 0x0e, 0x1e, 0x8e, 0x51, 0x4f, 0xa4, 0xe1, 0x4a, 0x1b, 0x5e, 0x14, 0xa8, 0xbe,
+	0xc7, 0xb9, 0x29, 0x95, 0xa6, 0x03, 0x7f, 0xf2, 0x3f, 0x44, 0xa3, 0xbf, 0x43, 0x88, 0x9d, 0xff,
+	0xcf, 0xcb, 0xc5, 0xd6, 0xde, 0x86, 0xfd, 0xdb, 0x38, 0xbf, 0xeb, 0xfe, 0xb7, 0xed, 0xef, 0x40,
+	0xf4, 0x52, 0x95, 0x9b, 0xca, 0xb9, 0x1d, 0xb3, 0xba, 0x39, 0x69, 0xf2, 0x23, 0x18, 0xe4, 0x15,
+	0x9f, 0x1b, 0x85, 0x45, 0x7d, 0x4c, 0xb5, 0xc1, 0x6d, 0x90, 0x7c, 0x0c, 0x30, 0xd1, 0x53, 0x14,
+	0xf5, 0xc7, 0xa9, 0xe5, 0xae, 0x47, 0xd4, 0x32, 0x9b, 0x0b, 0xfb, 0x69, 0x6c, 0xfb, 0xeb, 0x11,
+	0x36, 0x58, 0x33, 0xae, 0x14, 0x2e, 0x4b, 0xb5, 0x16, 0x72, 0xe5, 0xee, 0xdf, 0x39, 0x1d, 0xb3,
+	0x63, 0xc2, 0xaa, 0xf7, 0x61, 0xdd, 0x1d, 0xac, 0x73, 0x3c, 0x60, 0xc7, 0x04, 0x79, 0x0c, 0x91,
+	0x12, 0x72, 0xa5, 0x69, 0xe2, 0x6e, 0x70, 0xe8, 0xdf, 0x20, 0x13, 0x72, 0xc5, 0x6a, 0x7a, 0xf4,
+	0x67, 0x00, 0x71, 0x03, 0x36, 0xfb, 0x0d, 0xbc, 0xfd, 0xde, 0x6f, 0x2b, 0xf4, 0xb6, 0x95, 0x42,
+	0x32, 0x91, 0x92, 0x2b, 0x86, 0x0b, 0xb1, 0xd1, 0xb4, 0xe3, 0x7e, 0x85, 0x0f, 0x59, 0xc5, 0xab,
+	0x8d, 0x69, 0x14, 0xdd, 0x5a, 0xe1, 0x41, 0xf6, 0xb9, 0x53, 0xbb, 0xe8, 0xc8, 0x51, 0xae, 0xfe,
+	0x77, 0x00, 0xde, 0x50, 0x52, 0x19, 0xfd, 0x06, 0x00, 0x00,
 }
@@ -0,0 +1,254 @@
+package structs
+
+import (
+	"math"
+	"sort"
+)
+
+type SpaceBucket struct {
+	Systems []*SpaceSystem
+	X       int
+	Y       int
+	Z       int
+}
+
+/**
+ * SpaceGrid stores systems in a sparse grid of cubic buckets, each Radius light years wide.
+ * Only buckets that contain systems are kept, so the size of the grid depends on how many
+ * systems there are rather than how much of space they cover.
+ */
+type SpaceGrid struct {
+	Radius  float64
+	buckets map[cell]*SpaceBucket // access via GetBucket()
+	count   int
+
+	// Buckets at the corners of the bounding box of all systems in the grid.
+	minCell, maxCell cell
+}
+
+type cell [3]int
+
+func NewSpaceGrid(radius float64) *SpaceGrid {
+	return &SpaceGrid{
+		Radius:  radius,
+		buckets: make(map[cell]*SpaceBucket),
+	}
+}
+
+/**
+ * Identifies the bucket coordinates of the given system.
+ */
+func (grid *SpaceGrid) FindBucket(system *SpaceSystem) (SystemID, SystemID, SystemID) {
+	x := int(math.Floor(system.X / grid.Radius))
+	y := int(math.Floor(system.Y / grid.Radius))
+	z := int(math.Floor(system.Z / grid.Radius))
+
+	return SystemID(x), SystemID(y), SystemID(z)
+}
+
+/**
+ * Adds the system to its bucket, creating the bucket if this is the first system in the cell.
+ */
+func (grid *SpaceGrid) Insert(system *SpaceSystem) {
+	x, y, z := grid.FindBucket(system)
+	position := cell{int(x), int(y), int(z)}
+
+	bucket, exists := grid.buckets[position]
+	if !exists {
+		bucket = &SpaceBucket{X: position[0], Y: position[1], Z: position[2]}
+		grid.buckets[position] = bucket
+	}
+
+	system.Bucket = bucket
+	system.Bucket.Systems = append(system.Bucket.Systems, system)
+
+	for axis := range position {
+		if grid.count == 0 || position[axis] < grid.minCell[axis] {
+			grid.minCell[axis] = position[axis]
+		}
+
+		if grid.count == 0 || position[axis] > grid.maxCell[axis] {
+			grid.maxCell[axis] = position[axis]
+		}
+	}
+
+	grid.count++
+}
+
+/**
+ * Return pointers to all systems strictly within the specified radius of the origin.
+ */
+func (grid *SpaceGrid) Within(origin *SpaceSystem, radius float64) []*SpaceSystem {
+	var items []*SpaceSystem
+
+	// Get all nearby buckets (including the current one) and scan all systems in each bucket.
+	buckets := grid.NearbyBuckets(origin, radius)
+	for _, bucket := range buckets {
+		for _, loc := range bucket.Systems {
+			if origin.DistanceTo(loc) < radius {
+				items = append(items, loc)
+			}
+		}
+	}
+
+	return items
+}
+
+/**
+ * Returns the k systems closest to the origin, closest first. The grid can only answer radius
+ * queries, so this keeps doubling the radius until there are at least k systems inside it; the
+ * closest k systems are always among them.
+ */
+func (grid *SpaceGrid) Nearest(origin *SpaceSystem, k int) []*SpaceSystem {
+	if k <= 0 || grid.count == 0 || !origin.HasFiniteCoords() {
+		return nil
+	}
+
+	var items []*SpaceSystem
+	for radius := grid.Radius; ; radius *= 2 {
+		items = grid.Within(origin, radius)
+
+		// Doubling gets stuck once the radius is infinite, so there's no point going any further.
+		if len(items) >= k || len(items) == grid.count || math.IsInf(radius, 1) {
+			break
+		}
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		return origin.DistanceTo(items[i]) < origin.DistanceTo(items[j])
+	})
+
+	if len(items) > k {
+		items = items[:k]
+	}
+
+	return items
+}
+
+/**
+ * Returns all systems inside the box with corners at low and high, including its edges.
+ */
+func (grid *SpaceGrid) InBox(low *SpaceSystem, high *SpaceSystem) []*SpaceSystem {
+	var items []*SpaceSystem
+
+	if grid.count == 0 {
+		return items
+	}
+
+	minX, minY, minZ := grid.FindBucket(low)
+	maxX, maxY, maxZ := grid.FindBucket(high)
+
+	for x := max(int(minX), grid.minCell[0]); x <= min(int(maxX), grid.maxCell[0]); x++ {
+		for y := max(int(minY), grid.minCell[1]); y <= min(int(maxY), grid.maxCell[1]); y++ {
+			for z := max(int(minZ), grid.minCell[2]); z <= min(int(maxZ), grid.maxCell[2]); z++ {
+				bucket, exists := grid.buckets[cell{x, y, z}]
+				if !exists {
+					continue
+				}
+
+				for _, system := range bucket.Systems {
+					if inBox(system, low, high) {
+						items = append(items, system)
+					}
+				}
+			}
+		}
+	}
+
+	return items
+}
+
+/**
+ * Return all buckets within the radius of the origin, including the bucket the origin is currently in.
+ * It should only return each bucket one time, and buckets will be pointers to the actual buckets.
+ *
+ * This checks every bucket in the cube surrounding the search sphere and keeps the ones that the
+ * sphere actually overlaps, so it works for any combination of radius and cell size. Empty buckets
+ * aren't stored, so they're never returned.
+ */
+func (grid *SpaceGrid) NearbyBuckets(origin *SpaceSystem, radius float64) []*SpaceBucket {
+	var buckets []*SpaceBucket
+
+	if grid.count == 0 {
+		return buckets
+	}
+
+	minX, minY, minZ := grid.FindBucket(&SpaceSystem{X: origin.X - radius, Y: origin.Y - radius, Z: origin.Z - radius})
+	maxX, maxY, maxZ := grid.FindBucket(&SpaceSystem{X: origin.X + radius, Y: origin.Y + radius, Z: origin.Z + radius})
+
+	// There's no point looking outside of the area that contains systems.
+	low := cell{max(int(minX), grid.minCell[0]), max(int(minY), grid.minCell[1]), max(int(minZ), grid.minCell[2])}
+	high := cell{min(int(maxX), grid.maxCell[0]), min(int(maxY), grid.maxCell[1]), min(int(maxZ), grid.maxCell[2])}
+
+	if low[0] > high[0] || low[1] > high[1] || low[2] > high[2] {
+		return buckets
+	}
+
+	// If the cube covers more buckets than actually exist, it's quicker to check each of the
+	// buckets that exist than each position in the cube.
+	volume := float64(high[0]-low[0]+1) * float64(high[1]-low[1]+1) * float64(high[2]-low[2]+1)
+	if volume > float64(len(grid.buckets)) {
+		for position, bucket := range grid.buckets {
+			if grid.overlaps(origin, radius, position) {
+				buckets = append(buckets, bucket)
+			}
+		}
+
+		return buckets
+	}
+
+	for x := low[0]; x <= high[0]; x++ {
+		for y := low[1]; y <= high[1]; y++ {
+			for z := low[2]; z <= high[2]; z++ {
+				if bucket, exists := grid.buckets[cell{x, y, z}]; exists && grid.overlaps(origin, radius, cell{x, y, z}) {
+					buckets = append(buckets, bucket)
+				}
+			}
+		}
+	}
+
+	return buckets
+}
+
+/**
+ * Returns true if any part of the bucket at the specified position is within the radius of the origin.
+ */
+func (grid *SpaceGrid) overlaps(origin *SpaceSystem, radius float64, position cell) bool {
+	size := grid.Radius
+
+	// Find the closest point in the bucket to the origin.
+	dx := axisDistance(origin.X, float64(position[0])*size, size)
+	dy := axisDistance(origin.Y, float64(position[1])*size, size)
+	dz := axisDistance(origin.Z, float64(position[2])*size, size)
+
+	return dx*dx+dy*dy+dz*dz <= radius*radius
+}
+
+/**
+ * Distance along a single axis from a point to the closest edge of a cell that starts at `start`,
+ * or zero if the point is inside the cell.
+ */
+func axisDistance(point float64, start float64, size float64) float64 {
+	if point < start {
+		return start - point
+	} else if point > start+size {
+		return point - (start + size)
+	}
+
+	return 0
+}
+
+/**
+ * Simple function to provide an abstraction over the bucket storage schema. Returns nil if there
+ * aren't any systems in the bucket.
+ */
+func (grid *SpaceGrid) GetBucket(x SystemID, y SystemID, z SystemID) *SpaceBucket {
+	return grid.buckets[cell{int(x), int(y), int(z)}]
+}
+
+/**
+ * Returns the number of buckets that contain systems.
+ */
+func (grid *SpaceGrid) BucketCount() int {
+	return len(grid.buckets)
+}
@@ -0,0 +1,197 @@
+package structs
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"sync"
+)
+
+/**
+ * LegCache remembers the routes found between pairs of systems so that each distinct leg only
+ * needs to be solved once. It's meant to live for a single request, and can optionally be backed
+ * by a RouteCache that's shared across requests.
+ */
+type LegCache struct {
+	graph  *SpaceGraph
+	shared *RouteCache
+
+	lock sync.Mutex
+	legs map[string]*legResult
+}
+
+type legResult struct {
+	route *SpaceRoute
+	err   error
+}
+
+func NewLegCache(graph *SpaceGraph, shared *RouteCache) *LegCache {
+	return &LegCache{
+		graph:  graph,
+		shared: shared,
+		legs:   make(map[string]*legResult),
+	}
+}
+
+/**
+ * Same as SpaceGraph.FindPath, but reuses previous results for the same leg. Routes returned from
+ * here are copies, so callers are free to modify them. Searches that are cancelled aren't cached,
+ * and neither are searches with custom rules (see cacheable).
+ */
+func (cache *LegCache) FindPath(ctx context.Context, from *SpaceSystem, to *SpaceSystem, cons *RoutingConstraints) (*SpaceRoute, error) {
+	if from == nil || to == nil || !cons.cacheable() {
+		return cache.graph.FindPath(ctx, from, to, cons)
+	}
+
+	key := LegKey(from, to, cons)
+
+	cache.lock.Lock()
+	result, exists := cache.legs[key]
+	cache.lock.Unlock()
+
+	if !exists && cache.shared != nil {
+		result, exists = cache.shared.get(key)
+	}
+
+	if !exists {
+		route, err := cache.graph.FindPath(ctx, from, to, cons)
+
+		// A search that was cut short doesn't say anything about whether there's a route.
+		if ctx.Err() != nil {
+			return route, err
+		}
+
+		result = &legResult{route: route, err: err}
+		if cache.shared != nil {
+			cache.shared.put(key, result)
+		}
+	}
+
+	cache.lock.Lock()
+	cache.legs[key] = result
+	cache.lock.Unlock()
+
+	return result.route.Copy(), result.err
+}
+
+/**
+ * Returns a key that uniquely identifies a leg between two systems under a set of constraints.
+ * Every field that changes the route needs to be listed here. Custom rules aren't, since there's
+ * no telling functions apart, so the key only applies to constraints that are cacheable.
+ */
+func LegKey(from *SpaceSystem, to *SpaceSystem, cons *RoutingConstraints) string {
+	ship := Ship{}
+	if cons.Ship != nil {
+		ship = *cons.Ship
+	}
+
+	start := "full"
+	if cons.StartFuel != nil {
+		start = fmt.Sprint(*cons.StartFuel)
+	}
+
+	return fmt.Sprintf("%d:%d:%+v", from.ID, to.ID, []interface{}{
+		cons.MaxJump, cons.MaxHops, cons.MaxExpansions, ship,
+		cons.TankSize, cons.FuelPerJump, start, cons.PadSize,
+		cons.Weight, cons.Greedy, cons.Supercharge, cons.MinimizeTime, cons.ScoopRate,
+		cons.AvoidSystems, cons.AvoidRegions, cons.AvoidTypes, cons.AvoidHazards, cons.Penalties,
+		cons.Permits, cons.IgnorePermits,
+	})
+}
+
+/**
+ * Returns true if routes found with the constraints can be cached. Routes that use custom rules
+ * (or the blocks that FindAlternatives adds) can't be, since LegKey can't tell them apart.
+ */
+func (cons *RoutingConstraints) cacheable() bool {
+	return cons.Cost == nil && cons.Filter == nil && cons.Range == nil && cons.Hops == nil && cons.Heuristic == nil && cons.blocked == nil
+}
+
+/**
+ * RouteCache is a fixed size, least recently used cache of routes that's safe to share between
+ * requests. Everything in the cache is thrown away whenever the graph changes.
+ */
+type RouteCache struct {
+	graph    *SpaceGraph
+	capacity int
+	version  int // graph version that the cached routes were found with
+
+	lock    sync.Mutex
+	order   *list.List // most recently used at the front
+	entries map[string]*list.Element
+}
+
+type routeCacheEntry struct {
+	key    string
+	result *legResult
+}
+
+func NewRouteCache(graph *SpaceGraph, capacity int) *RouteCache {
+	return &RouteCache{
+		graph:    graph,
+		capacity: capacity,
+		version:  graph.Version(),
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+}
+
+/**
+ * Returns the cached result for the key, and whether there was one.
+ */
+func (cache *RouteCache) get(key string) (*legResult, bool) {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+
+	cache.checkVersion()
+
+	if element, exists := cache.entries[key]; exists {
+		cache.order.MoveToFront(element)
+		return element.Value.(*routeCacheEntry).result, true
+	}
+
+	return nil, false
+}
+
+func (cache *RouteCache) put(key string, result *legResult) {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+
+	cache.checkVersion()
+
+	if element, exists := cache.entries[key]; exists {
+		element.Value.(*routeCacheEntry).result = result
+		cache.order.MoveToFront(element)
+		return
+	}
+
+	cache.entries[key] = cache.order.PushFront(&routeCacheEntry{key: key, result: result})
+
+	// Evict the least recently used route if we're over capacity.
+	if cache.order.Len() > cache.capacity {
+		oldest := cache.order.Back()
+		cache.order.Remove(oldest)
+		delete(cache.entries, oldest.Value.(*routeCacheEntry).key)
+	}
+}
+
+func (cache *RouteCache) Len() int {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+
+	cache.checkVersion()
+
+	return cache.order.Len()
+}
+
+/**
+ * Empties the cache if the graph has changed since the routes in it were found. Must be called
+ * with the lock held.
+ */
+func (cache *RouteCache) checkVersion() {
+	if version := cache.graph.Version(); version != cache.version {
+		cache.order.Init()
+		cache.entries = make(map[string]*list.Element)
+		cache.version = version
+	}
+}
@@ -0,0 +1,80 @@
+package structs
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLegCache(t *testing.T) {
+	graph := InitGraph(1000).LoadSample()
+	legs := NewLegCache(graph, nil)
+	cons := &RoutingConstraints{MaxHops: 5, MaxJump: 5}
+
+	first, _ := legs.FindPath(context.Background(), graph.Get(1), graph.Get(4), cons)
+	if assert.NotNil(t, first, "no path found") {
+		first.Stops[0].RequestedStop = true
+
+		second, _ := legs.FindPath(context.Background(), graph.Get(1), graph.Get(4), cons)
+		assert.Equal(t, first.Distance, second.Distance)
+		assert.False(t, second.Stops[0].RequestedStop, "cached routes shouldn't share stops")
+	}
+
+	assert.Equal(t, 1, len(legs.legs))
+
+	// Different constraints are a different leg.
+	legs.FindPath(context.Background(), graph.Get(1), graph.Get(4), &RoutingConstraints{MaxHops: 5, MaxJump: 10})
+	assert.Equal(t, 2, len(legs.legs))
+}
+
+func TestRouteCache(t *testing.T) {
+	graph := InitGraph(1000).LoadSample()
+	shared := NewRouteCache(graph, 2)
+	cons := &RoutingConstraints{MaxHops: 5, MaxJump: 5}
+
+	NewLegCache(graph, shared).FindPath(context.Background(), graph.Get(1), graph.Get(4), cons)
+	NewLegCache(graph, shared).FindPath(context.Background(), graph.Get(1), graph.Get(2), cons)
+
+	_, exists := shared.get(LegKey(graph.Get(1), graph.Get(4), cons))
+	assert.True(t, exists, "route should be shared across leg caches")
+
+	// Adding a third leg should evict the least recently used one.
+	NewLegCache(graph, shared).FindPath(context.Background(), graph.Get(2), graph.Get(4), cons)
+	assert.Equal(t, 2, shared.Len())
+
+	_, exists = shared.get(LegKey(graph.Get(1), graph.Get(2), cons))
+	assert.False(t, exists, "least recently used route should have been evicted")
+
+	// Keys depend on how much fuel there is, not where it's stored.
+	assert.Equal(t, LegKey(graph.Get(1), graph.Get(2), &RoutingConstraints{StartFuel: tons(1)}), LegKey(graph.Get(1), graph.Get(2), &RoutingConstraints{StartFuel: tons(1)}))
+	assert.NotEqual(t, LegKey(graph.Get(1), graph.Get(2), &RoutingConstraints{StartFuel: tons(1)}), LegKey(graph.Get(1), graph.Get(2), &RoutingConstraints{StartFuel: tons(2)}))
+
+	// Changing the graph invalidates everything.
+	graph.Add(&SpaceSystem{ID: 7, Name: "Seventh Site", X: 1, Y: 1, Z: 1})
+	assert.Equal(t, 0, shared.Len())
+}
+
+func TestRouteCacheCustomRules(t *testing.T) {
+	graph := chokepointGraph()
+	shared := NewRouteCache(graph, 10)
+
+	// Both filters are the same function, but they avoid different systems.
+	avoid := func(id SystemID) EdgeFilter {
+		return EdgeFilterFunc(func(from *SpaceSystem, to *SpaceSystem) bool { return to.ID != id })
+	}
+
+	for avoided, expected := range map[SystemID]SystemID{2: 3, 3: 2} {
+		route, _ := NewLegCache(graph, shared).FindPath(context.Background(), graph.Get(1), graph.Get(4), &RoutingConstraints{
+			MaxHops: 5,
+			MaxJump: 5.5,
+			Filter:  avoid(avoided),
+		})
+
+		if assert.NotNil(t, route, "no path found avoiding %d", avoided) {
+			assert.Equal(t, expected, route.Stops[1].System.ID)
+		}
+	}
+
+	assert.Equal(t, 0, shared.Len(), "routes with custom rules shouldn't be cached")
+}
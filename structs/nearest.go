@@ -0,0 +1,149 @@
+package structs
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"strings"
+)
+
+/**
+ * SystemFilter decides whether a system is one that we're looking for.
+ */
+type SystemFilter func(system *SpaceSystem) bool
+
+/**
+ * Filters that can be requested by name.
+ */
+var Filters = map[string]SystemFilter{
+	"any":        func(system *SpaceSystem) bool { return true },
+	"scoopable":  func(system *SpaceSystem) bool { return system.ContainsScoopableStar },
+	"station":    func(system *SpaceSystem) bool { return system.ContainsRefuelStation },
+	"refuel":     func(system *SpaceSystem) bool { return system.CanRefuel() },
+	"neutron":    func(system *SpaceSystem) bool { return system.ArrivalNeutronStar },
+	"whitedwarf": func(system *SpaceSystem) bool { return system.ArrivalWhiteDwarf },
+	"permit":     func(system *SpaceSystem) bool { return system.NeedsPermit },
+	"blackhole":  func(system *SpaceSystem) bool { return system.ArrivalHazard() == HazardBlackHole },
+	"hazardous":  func(system *SpaceSystem) bool { return system.ArrivalHazard() != "" },
+
+	"highsec": func(system *SpaceSystem) bool { return system.Security == "High" },
+	"lowsec":  func(system *SpaceSystem) bool { return system.Security == "Low" },
+	"anarchy": func(system *SpaceSystem) bool {
+		return system.Security == "Anarchy" || system.Government == "Anarchy"
+	},
+
+	"war":         func(system *SpaceSystem) bool { return system.HasState("War") || system.HasState("Civil War") },
+	"lockdown":    func(system *SpaceSystem) bool { return system.HasState("Lockdown") },
+	"civilunrest": func(system *SpaceSystem) bool { return system.HasState("Civil Unrest") },
+	"outbreak":    func(system *SpaceSystem) bool { return system.HasState("Outbreak") },
+}
+
+/**
+ * Parses a comma-delimited list of filter names into a single filter that only matches systems
+ * that pass all of them. An empty list matches everything.
+ */
+func ParseFilter(names string) (SystemFilter, error) {
+	var filters []SystemFilter
+
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(strings.ToLower(name))
+		if name == "" {
+			continue
+		}
+
+		filter, exists := Filters[name]
+		if !exists {
+			return nil, errors.New("unknown filter " + name)
+		}
+
+		filters = append(filters, filter)
+	}
+
+	return func(system *SpaceSystem) bool {
+		for _, filter := range filters {
+			if !filter(system) {
+				return false
+			}
+		}
+
+		return true
+	}, nil
+}
+
+/**
+ * A system found by FindNearest. Jumps isn't filled in by FindNearest; callers that want to know
+ * how many jumps away the system is need to find a route to it, and should set Jumps to -1 if
+ * there isn't one.
+ */
+type NearbySystem struct {
+	System   *SpaceSystem
+	Distance float64 // straight-line distance from the origin, in LY
+	Jumps    int
+}
+
+/**
+ * Returns up to `limit` systems that match the filter, closest to the origin first. If
+ * maxDistance is more than zero then only systems within that distance are considered. The
+ * origin is never included in the results, and doesn't need to be a system in the graph. Nothing
+ * is found around an origin with non-finite coordinates.
+ *
+ * This looks through shells around the origin, each one twice as far out as the last, until
+ * it's found enough matches. Filters that match lots of systems are much faster than ones that
+ * don't, since the search stops closer to the origin.
+ */
+func (graph *SpaceGraph) FindNearest(origin *SpaceSystem, filter SystemFilter, limit int, maxDistance float64) []*NearbySystem {
+	var matches []*NearbySystem
+
+	if limit <= 0 || !origin.HasFiniteCoords() {
+		return matches
+	}
+
+	reach := graph.reach(origin)
+	for inner, outer := 0.0, math.Max(graph.Radius, 1); ; inner, outer = outer, outer*2 {
+		if maxDistance > 0 && outer > maxDistance {
+			outer = maxDistance
+		}
+
+		// Everything closer than the inner edge of the shell has been looked at already.
+		var shell []*NearbySystem
+		for _, candidate := range graph.Proximity(origin, outer) {
+			distance := origin.DistanceTo(candidate)
+			if distance >= inner && candidate != origin && filter(candidate) {
+				shell = append(shell, &NearbySystem{System: candidate, Distance: distance})
+			}
+		}
+
+		sort.Slice(shell, func(i, j int) bool {
+			if shell[i].Distance != shell[j].Distance {
+				return shell[i].Distance < shell[j].Distance
+			}
+
+			return shell[i].System.ID < shell[j].System.ID
+		})
+
+		for _, match := range shell {
+			matches = append(matches, match)
+			if len(matches) == limit {
+				return matches
+			}
+		}
+
+		if outer > reach || outer == maxDistance {
+			return matches
+		}
+	}
+}
+
+/**
+ * Returns the distance from the origin to the furthest corner of the graph's bounding box, which
+ * is as far away as any system in the graph can be.
+ */
+func (graph *SpaceGraph) reach(origin *SpaceSystem) float64 {
+	total := 0.0
+	for axis, coord := range [3]float64{origin.X, origin.Y, origin.Z} {
+		furthest := math.Max(math.Abs(coord-graph.min[axis]), math.Abs(coord-graph.max[axis]))
+		total += furthest * furthest
+	}
+
+	return math.Sqrt(total)
+}
@@ -0,0 +1,122 @@
+package structs
+
+import (
+	"math"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindNearest(t *testing.T) {
+	graph := InitGraph(10)
+
+	// A line of systems along the x axis, where every third one has a scoopable star.
+	for i := 0; i < 300; i++ {
+		graph.Add(&SpaceSystem{ID: SystemID(i + 1), X: float64(i), ContainsScoopableStar: i%3 == 0})
+	}
+
+	scoopable := Filters["scoopable"]
+
+	matches := graph.FindNearest(graph.Get(2), scoopable, 3, 0)
+	assert.Equal(t, []SystemID{1, 4, 7}, nearbyIDs(matches))
+	assert.Equal(t, []float64{1, 2, 5}, []float64{matches[0].Distance, matches[1].Distance, matches[2].Distance})
+
+	// The origin itself isn't included, even if it matches.
+	assert.Equal(t, []SystemID{4, 7}, nearbyIDs(graph.FindNearest(graph.Get(1), scoopable, 2, 0)))
+
+	// Arbitrary coordinates work too.
+	assert.Equal(t, []SystemID{151}, nearbyIDs(graph.FindNearest(&SpaceSystem{X: 150.2, Y: 3}, scoopable, 1, 0)))
+
+	// Matches have to be within the maximum distance.
+	assert.Equal(t, []SystemID{1, 4}, nearbyIDs(graph.FindNearest(graph.Get(2), scoopable, 10, 3)))
+
+	// Rare matches need the search to keep expanding.
+	rare := func(system *SpaceSystem) bool { return system.ID == 300 }
+	assert.Equal(t, []SystemID{300}, nearbyIDs(graph.FindNearest(graph.Get(1), rare, 5, 0)))
+
+	none := func(system *SpaceSystem) bool { return false }
+	assert.Equal(t, 0, len(graph.FindNearest(graph.Get(1), none, 5, 0)))
+}
+
+func TestFindNearestMatchesBruteForce(t *testing.T) {
+	for _, index := range []SpatialIndex{nil, NewKDTree()} {
+		graph := InitGraph(5)
+		systems := randomSystems(500, 200)
+		for _, system := range systems {
+			graph.Add(system)
+		}
+
+		if index != nil {
+			graph.UseIndex(index)
+		}
+
+		rare := func(system *SpaceSystem) bool { return system.ID%37 == 0 }
+		origin := &SpaceSystem{X: 80, Y: -60, Z: 10}
+
+		for _, maxDistance := range []float64{0, 100} {
+			expected := []SystemID{}
+			sorted := append([]*SpaceSystem{}, systems...)
+			sort.Slice(sorted, func(i, j int) bool { return origin.DistanceTo(sorted[i]) < origin.DistanceTo(sorted[j]) })
+
+			for _, system := range sorted {
+				if rare(system) && (maxDistance == 0 || origin.DistanceTo(system) < maxDistance) && len(expected) < 8 {
+					expected = append(expected, system.ID)
+				}
+			}
+
+			assert.Equal(t, expected, nearbyIDs(graph.FindNearest(origin, rare, 8, maxDistance)), "max distance %v", maxDistance)
+		}
+	}
+}
+
+func TestFindNearestNonFiniteOrigin(t *testing.T) {
+	everything := Filters["any"]
+
+	for _, origin := range []*SpaceSystem{{X: math.NaN()}, {Y: math.Inf(1)}, {Z: math.Inf(-1)}} {
+		for _, index := range []SpatialIndex{nil, NewKDTree()} {
+			graph := InitGraph(5)
+			for _, system := range randomSystems(50, 100) {
+				graph.Add(system)
+			}
+
+			if index != nil {
+				graph.UseIndex(index)
+			}
+
+			// These used to search forever, since no shell ever reached past the edge of the graph.
+			assert.Equal(t, 0, len(graph.FindNearest(origin, everything, 5, 0)), "origin %+v", origin)
+			assert.Equal(t, 0, len(graph.FindNearest(origin, everything, 5, 100)), "origin %+v", origin)
+		}
+
+		grid := NewSpaceGrid(5)
+		grid.Insert(&SpaceSystem{ID: 1})
+		assert.Nil(t, grid.Nearest(origin, 1), "origin %+v", origin)
+		assert.False(t, origin.HasFiniteCoords())
+	}
+
+	assert.True(t, (&SpaceSystem{X: 1e300}).HasFiniteCoords())
+}
+
+func TestParseFilter(t *testing.T) {
+	filter, err := ParseFilter("scoopable, station")
+	assert.Nil(t, err)
+	assert.True(t, filter(&SpaceSystem{ContainsScoopableStar: true, ContainsRefuelStation: true}))
+	assert.False(t, filter(&SpaceSystem{ContainsScoopableStar: true}))
+
+	filter, err = ParseFilter("")
+	assert.Nil(t, err)
+	assert.True(t, filter(&SpaceSystem{}))
+
+	_, err = ParseFilter("scoopable,shiny")
+	assert.NotNil(t, err)
+}
+
+func nearbyIDs(matches []*NearbySystem) []SystemID {
+	ids := make([]SystemID, len(matches))
+	for i, match := range matches {
+		ids[i] = match.System.ID
+	}
+
+	return ids
+}
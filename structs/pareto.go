@@ -0,0 +1,278 @@
+package structs
+
+import (
+	"container/heap"
+	"context"
+	"math"
+)
+
+const SearchPareto = "pareto"
+
+/**
+ * The criteria that routes are compared on when looking for the Pareto front. Lower is better
+ * for all of them.
+ */
+type RouteCriteria struct {
+	Jumps       int
+	Distance    float64
+	Unscoopable int // stops along the way (not counting the destination) without a scoopable star
+}
+
+/**
+ * Returns true if the criteria are at least as good as the other ones across the board.
+ */
+func (criteria RouteCriteria) covers(other RouteCriteria) bool {
+	return criteria.Jumps <= other.Jumps && criteria.Distance <= other.Distance && criteria.Unscoopable <= other.Unscoopable
+}
+
+/**
+ * Returns true if the criteria are at least as good as the other ones across the board, and
+ * better in at least one way.
+ */
+func (criteria RouteCriteria) Dominates(other RouteCriteria) bool {
+	return criteria.covers(other) && criteria != other
+}
+
+/**
+ * A route on the Pareto front, along with the criteria it was judged on.
+ */
+type ParetoRoute struct {
+	Route    *SpaceRoute
+	Criteria RouteCriteria
+}
+
+/**
+ * Finds the Pareto front of routes between two systems: every route that isn't beaten on all of
+ * jumps, distance and unscoopable stops by some other route. Only one route is returned for each
+ * combination of criteria, and routes are ordered by fewest jumps first, then shortest distance.
+ *
+ * This is a label-setting search (Martins' algorithm): every system keeps the set of routes to
+ * it that aren't beaten by any other, and routes are expanded in lexicographic order so that
+ * each one is final once it's expanded. Routes are also dropped as soon as they can't beat the
+ * routes already found to the destination, even in the best case.
+ *
+ * Fuel isn't tracked (the number of unscoopable stops is the trade-off to look at instead), so
+ * jumps are limited to the reach with a full tank. Jumps follow the constraints' EdgeFilter,
+ * JumpReach and HopLimit, but EdgeCost and Heuristic aren't used since the criteria are fixed;
+ * each route's Cost is still measured with EdgeCost so that routes can be compared with
+ * FindPath's.
+ */
+func (graph *SpaceGraph) FindPareto(ctx context.Context, from *SpaceSystem, to *SpaceSystem, cons *RoutingConstraints) ([]*ParetoRoute, error) {
+	fail := func(err error) ([]*ParetoRoute, error) {
+		return nil, legError(from, to, err)
+	}
+
+	for _, system := range []*SpaceSystem{from, to} {
+		if err := graph.Contains(system); err != nil {
+			return fail(err)
+		}
+	}
+
+	if !cons.Permitted(to) {
+		return fail(ErrPermitRequired)
+	}
+
+	hopLimited := false
+
+	// The longest jump that could be made anywhere, for working out how many jumps are left at
+	// the least. Custom reaches could be anything, so they only count on one more jump.
+	reach := 0.0
+	if cons.Range == nil {
+		reach = cons.JumpRange(cons.Tank())
+		if cons.Supercharge {
+			reach *= NeutronSupercharge
+		}
+	}
+
+	// The best criteria a route through the label could possibly end up with.
+	bound := func(label *paretoLabel) RouteCriteria {
+		best := label.criteria
+		remaining := label.system.DistanceTo(to)
+
+		best.Distance += remaining
+		if remaining > 0 && reach > 0 {
+			best.Jumps += int(math.Max(1, math.Ceil(remaining/reach)))
+		} else if remaining > 0 {
+			best.Jumps++
+		}
+
+		return best
+	}
+
+	// Routes to each system that haven't been beaten yet, both final and queued.
+	labels := make(map[*SpaceSystem][]*paretoLabel)
+	beaten := func(system *SpaceSystem, criteria RouteCriteria) bool {
+		for _, label := range labels[system] {
+			if !label.dropped && label.criteria.covers(criteria) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	available := &paretoQueue{}
+	origin := &paretoLabel{system: from}
+	labels[from] = append(labels[from], origin)
+	heap.Push(available, origin)
+
+	filter, cost, jumps, hops := cons.EdgeFilter(), cons.EdgeCost(), cons.JumpReach(), cons.HopLimit()
+	var front []*paretoLabel
+
+	checks := 0
+	for available.Len() > 0 {
+		label := heap.Pop(available).(*paretoLabel)
+		if label.dropped {
+			continue
+		}
+
+		if label.system == to {
+			front = append(front, label)
+			continue
+		}
+
+		// Routes found to the destination since this was queued might already be better.
+		if beaten(to, bound(label)) {
+			continue
+		}
+
+		if !hops.AllowsHops(label.criteria.Jumps + 1) {
+			hopLimited = true
+			continue
+		}
+
+		if cons.MaxExpansions > 0 && checks >= cons.MaxExpansions {
+			return fail(ErrBudgetExhausted)
+		}
+
+		if checks%cancelCheckInterval == 0 && ctx.Err() != nil {
+			return fail(ctx.Err())
+		}
+
+		checks++
+
+		for _, near := range graph.Proximity(label.system, jumps.Reach(label.system, cons.Tank())) {
+			if near == label.system {
+				continue
+			}
+
+			if near != to && !filter.CanJump(label.system, near) {
+				continue
+			}
+
+			next := &paretoLabel{system: near, criteria: label.criteria, prev: label}
+			next.criteria.Jumps++
+			next.criteria.Distance += label.system.DistanceTo(near)
+			if near != to && !near.ContainsScoopableStar {
+				next.criteria.Unscoopable++
+			}
+
+			if beaten(near, next.criteria) || beaten(to, bound(next)) {
+				continue
+			}
+
+			// Anything queued for the same system that the new route beats can be forgotten.
+			// Labels that are already final can't be beaten, since they were expanded first.
+			kept := labels[near][:0]
+			for _, other := range labels[near] {
+				if next.criteria.covers(other.criteria) {
+					other.dropped = true
+				} else {
+					kept = append(kept, other)
+				}
+			}
+
+			labels[near] = append(kept, next)
+			heap.Push(available, next)
+		}
+	}
+
+	if len(front) == 0 {
+		if hopLimited {
+			return fail(ErrHopLimit)
+		}
+
+		return fail(ErrUnreachable)
+	}
+
+	routes := make([]*ParetoRoute, len(front))
+	for i, label := range front {
+		routes[i] = &ParetoRoute{Route: label.route(cons, cost, checks), Criteria: label.criteria}
+	}
+
+	return routes, nil
+}
+
+type paretoLabel struct {
+	system   *SpaceSystem
+	criteria RouteCriteria
+	prev     *paretoLabel // where we jumped from; nil at the origin
+	dropped  bool         // beaten by a route found later, before this one was expanded
+}
+
+/**
+ * Converts the chain of labels that led here into a route.
+ */
+func (label *paretoLabel) route(cons *RoutingConstraints, cost EdgeCost, checks int) *SpaceRoute {
+	var chain []*paretoLabel
+	for current := label; current != nil; current = current.prev {
+		chain = append([]*paretoLabel{current}, chain...)
+	}
+
+	route := &SpaceRoute{
+		Origin:      chain[0].system.AsStop(),
+		Destination: label.system.AsStop(),
+		Stops:       make([]*SpaceStop, len(chain)),
+		Distance:    label.criteria.Distance,
+		Mode:        SearchPareto,
+		Checks:      checks,
+	}
+
+	for i, current := range chain {
+		stop := current.system.AsStop()
+		if i > 0 {
+			stop.DistanceFromPrev = chain[i-1].system.DistanceTo(current.system)
+			route.Cost += cost.JumpCost(chain[i-1].system, current.system)
+		}
+
+		stop.Supercharge = i < len(chain)-1 && cons.NeedsSupercharge(current.system, chain[i+1].system, cons.Tank())
+		route.Stops[i] = stop
+	}
+
+	route.Duration = cons.Duration(route.Stops)
+
+	return route
+}
+
+/**
+ * Labels ordered lexicographically by jumps, then distance, then unscoopable stops.
+ */
+type paretoQueue struct {
+	labels []*paretoLabel
+}
+
+func (q *paretoQueue) Len() int { return len(q.labels) }
+
+func (q *paretoQueue) Less(i int, j int) bool {
+	a, b := q.labels[i].criteria, q.labels[j].criteria
+	if a.Jumps != b.Jumps {
+		return a.Jumps < b.Jumps
+	} else if a.Distance != b.Distance {
+		return a.Distance < b.Distance
+	}
+
+	return a.Unscoopable < b.Unscoopable
+}
+
+func (q *paretoQueue) Swap(i int, j int) { q.labels[i], q.labels[j] = q.labels[j], q.labels[i] }
+
+func (q *paretoQueue) Push(x interface{}) {
+	q.labels = append(q.labels, x.(*paretoLabel))
+}
+
+func (q *paretoQueue) Pop() interface{} {
+	last := q.labels[len(q.labels)-1]
+	q.labels = q.labels[:len(q.labels)-1]
+
+	return last
+}
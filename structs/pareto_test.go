@@ -0,0 +1,177 @@
+package structs
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func paretoGraph() *SpaceGraph {
+	graph := InitGraph(1000)
+	graph.Add(&SpaceSystem{ID: 1, Name: "Origin", X: 0, Y: 0, Z: 0, ContainsScoopableStar: true})
+	graph.Add(&SpaceSystem{ID: 2, Name: "Dry Shortcut", X: 10, Y: 4, Z: 0})
+	graph.Add(&SpaceSystem{ID: 3, Name: "Short Hop", X: 7, Y: 0, Z: 0, ContainsScoopableStar: true})
+	graph.Add(&SpaceSystem{ID: 4, Name: "Another Short Hop", X: 14, Y: 0, Z: 0, ContainsScoopableStar: true})
+	graph.Add(&SpaceSystem{ID: 5, Name: "Destination", X: 20, Y: 0, Z: 0})
+	graph.Add(&SpaceSystem{ID: 6, Name: "Scoopable Shortcut", X: 10, Y: -4.5, Z: 0, ContainsScoopableStar: true})
+
+	return graph
+}
+
+func TestFindPareto(t *testing.T) {
+	graph := paretoGraph()
+
+	front := func(cons *RoutingConstraints) ([][]SystemID, error) {
+		cons.MaxJump = 11
+		if cons.MaxHops == 0 {
+			cons.MaxHops = 10
+		}
+
+		routes, err := graph.FindPareto(context.Background(), graph.Get(1), graph.Get(5), cons)
+		if err != nil {
+			return nil, err
+		}
+
+		var paths [][]SystemID
+		for _, route := range routes {
+			var ids []SystemID
+			for _, stop := range route.Route.Stops {
+				ids = append(ids, stop.System.ID)
+			}
+
+			paths = append(paths, ids)
+		}
+
+		return paths, nil
+	}
+
+	// Fewest jumps, fewest unscoopable stops and shortest distance are all different routes.
+	paths, err := front(&RoutingConstraints{})
+	assert.Nil(t, err)
+	assert.Equal(t, [][]SystemID{{1, 2, 5}, {1, 6, 5}, {1, 3, 4, 5}}, paths)
+
+	routes, _ := graph.FindPareto(context.Background(), graph.Get(1), graph.Get(5), &RoutingConstraints{MaxJump: 11, MaxHops: 10})
+	assert.Equal(t, RouteCriteria{Jumps: 2, Distance: routes[0].Route.Distance, Unscoopable: 1}, routes[0].Criteria)
+	assert.Equal(t, RouteCriteria{Jumps: 3, Distance: 20, Unscoopable: 0}, routes[2].Criteria)
+	assert.Equal(t, SearchPareto, routes[2].Route.Mode)
+	assert.InDelta(t, 20, routes[2].Route.Cost, 0.001)
+
+	for _, a := range routes {
+		for _, b := range routes {
+			assert.False(t, a.Criteria.Dominates(b.Criteria))
+		}
+	}
+
+	paths, _ = front(&RoutingConstraints{MaxHops: 2})
+	assert.Equal(t, [][]SystemID{{1, 2, 5}, {1, 6, 5}}, paths)
+
+	_, err = front(&RoutingConstraints{MaxHops: 1})
+	assert.True(t, errors.Is(err, ErrHopLimit))
+
+	paths, _ = front(&RoutingConstraints{AvoidSystems: map[SystemID]bool{6: true}})
+	assert.Equal(t, [][]SystemID{{1, 2, 5}, {1, 3, 4, 5}}, paths)
+
+	// The search only says there's no route; it's up to BlameRestrictions to say why.
+	avoided := &RoutingConstraints{AvoidSystems: map[SystemID]bool{2: true, 3: true, 6: true}}
+	_, err = front(avoided)
+	assert.True(t, errors.Is(err, ErrUnreachable))
+	assert.True(t, errors.Is(graph.BlameRestrictions(context.Background(), err, avoided), ErrAvoided))
+}
+
+func TestFindParetoMatchesBruteForce(t *testing.T) {
+	for trial := 0; trial < 10; trial++ {
+		graph := InitGraph(1000)
+		systems := randomSystems(14, 30)
+		for _, system := range systems {
+			system.ContainsScoopableStar = rand.Intn(2) == 0
+			graph.Add(system)
+		}
+
+		cons := &RoutingConstraints{MaxJump: 12, MaxHops: 5}
+		from, to := systems[0], systems[1]
+
+		// Every simple path within the hop limit.
+		var all []RouteCriteria
+		visited := map[*SpaceSystem]bool{from: true}
+
+		var walk func(at *SpaceSystem, criteria RouteCriteria)
+		walk = func(at *SpaceSystem, criteria RouteCriteria) {
+			if at == to {
+				all = append(all, criteria)
+				return
+			}
+
+			if criteria.Jumps == cons.MaxHops {
+				return
+			}
+
+			for _, near := range systems {
+				if visited[near] || at.DistanceTo(near) >= cons.MaxJump {
+					continue
+				}
+
+				next := criteria
+				next.Jumps++
+				next.Distance += at.DistanceTo(near)
+				if near != to && !near.ContainsScoopableStar {
+					next.Unscoopable++
+				}
+
+				visited[near] = true
+				walk(near, next)
+				visited[near] = false
+			}
+		}
+		walk(from, RouteCriteria{})
+
+		var expected []RouteCriteria
+		for _, a := range all {
+			dominated := false
+			for _, b := range all {
+				if b.Dominates(a) {
+					dominated = true
+				}
+			}
+
+			if !dominated {
+				expected = append(expected, a)
+			}
+		}
+
+		routes, err := graph.FindPareto(context.Background(), from, to, cons)
+		if len(expected) == 0 {
+			assert.NotNil(t, err)
+			continue
+		}
+
+		var found []RouteCriteria
+		for _, route := range routes {
+			found = append(found, route.Criteria)
+		}
+
+		byCriteria := func(list []RouteCriteria) func(i, j int) bool {
+			return func(i, j int) bool {
+				if list[i].Jumps != list[j].Jumps {
+					return list[i].Jumps < list[j].Jumps
+				}
+
+				return list[i].Distance < list[j].Distance
+			}
+		}
+		sort.Slice(expected, byCriteria(expected))
+
+		assert.Nil(t, err)
+		assert.Equal(t, len(expected), len(found), "trial %d", trial)
+		for i := range expected {
+			if i < len(found) {
+				assert.Equal(t, expected[i].Jumps, found[i].Jumps, "trial %d", trial)
+				assert.InDelta(t, expected[i].Distance, found[i].Distance, 0.0001, "trial %d", trial)
+				assert.Equal(t, expected[i].Unscoopable, found[i].Unscoopable, "trial %d", trial)
+			}
+		}
+	}
+}
@@ -5,12 +5,56 @@ import "container/heap"
 type SearchStop struct {
 	Location *SpaceSystem
 	Hops     int
+	Cost     float64     // cost of the route from the origin to this stop
+	Fuel     float64     // fuel in the tank on arrival
+	Refuel   bool        // whether the tank is topped up here before moving on
+	Prev     *SearchStop // where we jumped from; nil at the origin
+
+	dropped bool // beaten by a stop in the same system that was found later, before this one was expanded
+}
+
+/**
+ * Walk back through the chain of stops that led here and convert them into a list of SpaceStop's,
+ * ordered from the origin to this stop.
+ */
+func (stop *SearchStop) Unwind(cons *RoutingConstraints) []*SpaceStop {
+	var unwound []*SpaceStop
+	var after *SearchStop // where we jumped to from the current stop; nil at the end
+
+	for current := stop; current != nil; current = current.Prev {
+		next := current.Location.AsStop()
+
+		if current.Prev != nil {
+			next.DistanceFromPrev = current.Prev.Location.DistanceTo(current.Location)
+		}
+
+		if cons.TracksFuel() {
+			next.FuelRemaining = current.Fuel
+			next.Refuel = current.Refuel
+		}
+
+		if after != nil {
+			fuel := current.Fuel
+			if current.Refuel {
+				fuel = cons.Tank()
+			}
+
+			next.Supercharge = cons.NeedsSupercharge(current.Location, after.Location, fuel)
+		}
+
+		unwound = append([]*SpaceStop{next}, unwound...)
+		after = current
+	}
+
+	return unwound
 }
 
 func NewDestinationQueue(destination *SpaceSystem) destinationQueue {
 	dest := destinationQueue{
 		destination: destination,
 		elements:    make([]*SearchStop, 0), // todo: customize cap?
+		heuristic:   TravelCost,
+		weight:      1,
 	}
 
 	heap.Init(&dest)
@@ -18,7 +62,7 @@ func NewDestinationQueue(destination *SpaceSystem) destinationQueue {
 	return dest
 }
 
-/* TravelCost is the heuristic function for sorting queue */
+/* TravelCost is the default heuristic function for sorting queue */
 func TravelCost(from *SpaceSystem, to *SpaceSystem) float64 {
 	return from.DistanceTo(to)
 }
@@ -26,12 +70,28 @@ func TravelCost(from *SpaceSystem, to *SpaceSystem) float64 {
 type destinationQueue struct {
 	destination *SpaceSystem
 	elements    []*SearchStop
+	heuristic   func(*SpaceSystem, *SpaceSystem) float64 // estimated cost between two systems
+	weight      float64                                  // multiplier applied to the heuristic
+	greedy      bool                                     // order by the heuristic alone
+}
+
+/**
+ * Priority of a stop in the queue; lower is better. This is the A* estimate of the total cost
+ * of a route through the stop, or just the estimated cost remaining if we're being greedy.
+ */
+func (q destinationQueue) priority(stop *SearchStop) float64 {
+	estimate := q.weight * q.heuristic(stop.Location, q.destination)
+	if q.greedy {
+		return estimate
+	}
+
+	return stop.Cost + estimate
 }
 
 func (q destinationQueue) Len() int { return len(q.elements) }
 
 func (q destinationQueue) Less(i int, j int) bool {
-	return TravelCost(q.elements[i].Location, q.destination) < TravelCost(q.elements[j].Location, q.destination)
+	return q.priority(q.elements[i]) < q.priority(q.elements[j])
 }
 
 func (q destinationQueue) Swap(i int, j int) {
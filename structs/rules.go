@@ -0,0 +1,150 @@
+package structs
+
+/**
+ * The search is driven by five sets of rules: how much each jump costs, which jumps are allowed,
+ * how far the ship can jump, how many jumps a route can have, and how far from the destination
+ * each system seems to be. RoutingConstraints implements all of them with the standard rules,
+ * and any of them can be replaced by setting the matching field on the constraints (Cost,
+ * Filter, Range, Hops and Heuristic). Custom filters only ever add to the standard one, so
+ * permits and avoids are still respected.
+ *
+ * Some things are always handled by the search itself, whatever the rules are: fuel, and permits
+ * for the destination.
+ */
+
+/**
+ * EdgeCost decides how much it costs to jump directly from one system to another. Costs should
+ * never be negative. Supercruise and scooping are added on top using RoutingConstraints.TimeCost,
+ * so custom costs should be in the same units as the standard ones if either of those is used.
+ */
+type EdgeCost interface {
+	JumpCost(from *SpaceSystem, to *SpaceSystem) float64
+}
+
+/**
+ * EdgeFilter decides whether the ship is allowed to jump from one system to another, assuming
+ * it's in range. Jumps into the destination are always allowed, since it was asked for
+ * explicitly.
+ */
+type EdgeFilter interface {
+	CanJump(from *SpaceSystem, to *SpaceSystem) bool
+}
+
+/**
+ * JumpReach decides how far the ship can jump from a system, in LY, with the specified amount of
+ * fuel in the tank. Only systems within that distance are considered for the next jump. Fuel is
+ * still used up the standard way, so custom reaches shouldn't go further than the tank allows.
+ */
+type JumpReach interface {
+	Reach(from *SpaceSystem, fuel float64) float64
+}
+
+/**
+ * HopLimit decides whether a route is allowed to be made up of the specified number of jumps.
+ */
+type HopLimit interface {
+	AllowsHops(hops int) bool
+}
+
+/**
+ * Heuristic estimates the cost of getting from one system to another, which decides which
+ * systems the search looks at first. Routes are only guaranteed to be the cheapest if it never
+ * overestimates.
+ */
+type Heuristic interface {
+	Estimate(from *SpaceSystem, to *SpaceSystem) float64
+}
+
+// Adapters that allow plain functions to be used as rules.
+type EdgeCostFunc func(from *SpaceSystem, to *SpaceSystem) float64
+type EdgeFilterFunc func(from *SpaceSystem, to *SpaceSystem) bool
+type HeuristicFunc func(from *SpaceSystem, to *SpaceSystem) float64
+type JumpReachFunc func(from *SpaceSystem, fuel float64) float64
+type HopLimitFunc func(hops int) bool
+
+func (f EdgeCostFunc) JumpCost(from *SpaceSystem, to *SpaceSystem) float64  { return f(from, to) }
+func (f EdgeFilterFunc) CanJump(from *SpaceSystem, to *SpaceSystem) bool    { return f(from, to) }
+func (f HeuristicFunc) Estimate(from *SpaceSystem, to *SpaceSystem) float64 { return f(from, to) }
+func (f JumpReachFunc) Reach(from *SpaceSystem, fuel float64) float64       { return f(from, fuel) }
+func (f HopLimitFunc) AllowsHops(hops int) bool                             { return f(hops) }
+
+/**
+ * Returns the rules for the cost of each jump; the standard ones unless Cost is set.
+ */
+func (cons *RoutingConstraints) EdgeCost() EdgeCost {
+	if cons.Cost != nil {
+		return cons.Cost
+	}
+
+	return cons
+}
+
+/**
+ * Returns the rules for which jumps are allowed. Jumps always have to pass the standard rules,
+ * and Filter (if it's set) as well.
+ */
+func (cons *RoutingConstraints) EdgeFilter() EdgeFilter {
+	if cons.Filter != nil {
+		return EdgeFilterFunc(func(from *SpaceSystem, to *SpaceSystem) bool {
+			return cons.CanJump(from, to) && cons.Filter.CanJump(from, to)
+		})
+	}
+
+	return cons
+}
+
+/**
+ * Returns the rules for how far the ship can jump; the standard ones unless Range is set.
+ */
+func (cons *RoutingConstraints) JumpReach() JumpReach {
+	if cons.Range != nil {
+		return cons.Range
+	}
+
+	return cons
+}
+
+/**
+ * Returns the rules for how many jumps a route can have; the standard ones unless Hops is set.
+ */
+func (cons *RoutingConstraints) HopLimit() HopLimit {
+	if cons.Hops != nil {
+		return cons.Hops
+	}
+
+	return cons
+}
+
+/**
+ * Returns the heuristic for the search; the standard one unless Heuristic is set.
+ */
+func (cons *RoutingConstraints) Estimator() Heuristic {
+	if cons.Heuristic != nil {
+		return cons.Heuristic
+	}
+
+	return cons
+}
+
+/**
+ * The standard rule for which jumps are allowed: the ship needs a permit for the system it's
+ * jumping into (if it needs one), and the system can't be avoided.
+ */
+func (cons *RoutingConstraints) CanJump(from *SpaceSystem, to *SpaceSystem) bool {
+	return cons.Permitted(to) && !cons.Avoids(to)
+}
+
+/**
+ * The standard rule for how far the ship can jump: its jump range with the fuel that's in the
+ * tank, boosted if it can supercharge on the way out of the system.
+ */
+func (cons *RoutingConstraints) Reach(from *SpaceSystem, fuel float64) float64 {
+	return cons.JumpRange(fuel) * cons.SuperchargeFactor(from)
+}
+
+/**
+ * The standard rule for how many jumps a route can have: no more than MaxHops.
+ */
+func (cons *RoutingConstraints) AllowsHops(hops int) bool {
+	return hops <= cons.MaxHops
+}
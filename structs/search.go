@@ -2,25 +2,412 @@ package structs
 
 import (
 	"container/heap"
+	"context"
 	"errors"
 	"fmt"
 	"math"
 	"math/rand"
 )
 
-type SpaceBucket struct {
-	Systems []*SpaceSystem
-	X       int
-	Y       int
-	Z       int
-}
-
 /**
  * Options provided to the routing algorithm. These can be either hard or soft constraints.
  */
 type RoutingConstraints struct {
 	MaxJump float64
 	MaxHops int
+
+	// Most systems that the search is allowed to expand before giving up; unlimited if zero.
+	MaxExpansions int
+
+	// If a ship is provided then its jump range is used instead of MaxJump, and its tank is used
+	// instead of TankSize and FuelPerJump. The range is recalculated for each jump since it
+	// depends on how much fuel is left in the tank.
+	Ship *Ship
+
+	// Fuel is only tracked if TankSize is set. Ships can only refuel in systems that have a
+	// scoopable star or a refuel station, and will always fill the tank when they do.
+	TankSize    float64  // tons of fuel the ship can carry
+	FuelPerJump float64  // tons of fuel used by each jump
+	StartFuel   *float64 // tons of fuel in the tank at the origin; a full tank if nil
+
+	// Landing pad the ship needs ("S", "M" or "L"), which decides which stations it can refuel
+	// at. Overrides the ship's pad size if both are set; if neither is, any station will do.
+	PadSize string
+
+	// By default routes are found with A*, which always finds the cheapest route. Setting a
+	// Weight above 1 inflates the heuristic, which finds routes faster that may be up to Weight
+	// times more expensive than the best one. Greedy routing ignores the cost so far completely
+	// and is the fastest, with no guarantees about the route it finds.
+	Weight float64
+	Greedy bool
+
+	// Supercharge the FSD at neutron stars and white dwarfs whenever they're the arrival star.
+	// Supercharged routes minimize the number of jumps rather than the distance travelled,
+	// since using the "neutron highway" usually means taking a longer path.
+	Supercharge bool
+
+	// Minimize the estimated time the route takes to fly instead, in seconds (see JumpSeconds).
+	// Time spent scooping fuel is only counted if ScoopRate is set, in tons per second.
+	MinimizeTime bool
+	ScoopRate    float64
+
+	// Routes never pass through these systems or enter these regions. The origin and destination
+	// of a route are always allowed, since they were asked for explicitly.
+	AvoidSystems map[SystemID]bool
+	AvoidRegions []AvoidRegion
+	AvoidTypes   []string // names of Filters; systems that match any of them are avoided
+
+	// Stay out of systems with a hazardous arrival star (see SpaceSystem.ArrivalHazard). Routes
+	// that supercharge have to visit neutron stars and white dwarfs, so only black holes are
+	// avoided when Supercharge is set.
+	AvoidHazards bool
+
+	// Soft preferences, keyed by the name of a filter in Filters. Jumping into a system that
+	// matches a filter adds its penalty to the cost of the jump, so routes only pass through
+	// those systems when going around them would cost more. Penalties are in the same units as
+	// the cost: LY normally, jumps when supercharging and seconds when minimizing time.
+	Penalties map[string]float64
+
+	// Systems that need a permit can only be entered if the permit is listed here, unless
+	// IgnorePermits is set.
+	Permits       map[SystemID]bool
+	IgnorePermits bool
+
+	// Custom rules for the search (see rules.go). Any that aren't set use the standard rules
+	// described above, which the constraints implement themselves.
+	Cost      EdgeCost
+	Filter    EdgeFilter
+	Range     JumpReach
+	Hops      HopLimit
+	Heuristic Heuristic
+
+	// Systems and jumps that can't be used at all, not even into the destination. Only used
+	// while looking for alternative routes (see FindAlternatives).
+	blocked *spurBlocks
+}
+
+/**
+ * A sphere of space that routes should stay out of.
+ */
+type AvoidRegion struct {
+	X, Y, Z float64
+	Radius  float64
+}
+
+func (region AvoidRegion) Contains(system *SpaceSystem) bool {
+	return system.DistanceTo(&SpaceSystem{X: region.X, Y: region.Y, Z: region.Z}) < region.Radius
+}
+
+const (
+	SearchOptimal  = "astar"
+	SearchWeighted = "weighted-astar"
+	SearchGreedy   = "greedy"
+)
+
+/**
+ * Returns the size of landing pad the ship needs, or an empty string if it isn't known.
+ */
+func (cons *RoutingConstraints) LandingPad() string {
+	if cons.PadSize == "" && cons.Ship != nil {
+		return cons.Ship.PadSize
+	}
+
+	return cons.PadSize
+}
+
+/**
+ * Returns true if the ship can refuel in the system.
+ */
+func (cons *RoutingConstraints) CanRefuel(system *SpaceSystem) bool {
+	return system.CanRefuelWith(cons.LandingPad())
+}
+
+/**
+ * Returns true if routes should avoid passing through the system.
+ */
+func (cons *RoutingConstraints) Avoids(system *SpaceSystem) bool {
+	if cons.AvoidSystems[system.ID] {
+		return true
+	}
+
+	for _, region := range cons.AvoidRegions {
+		if region.Contains(system) {
+			return true
+		}
+	}
+
+	for _, name := range cons.AvoidTypes {
+		if filter, exists := Filters[name]; exists && filter(system) {
+			return true
+		}
+	}
+
+	if hazard := system.ArrivalHazard(); cons.AvoidHazards && hazard != "" {
+		return !cons.Supercharge || hazard == HazardBlackHole
+	}
+
+	return false
+}
+
+/**
+ * Returns the total penalty for entering the system. Negative penalties are ignored, since they
+ * would make the heuristic overestimate.
+ */
+func (cons *RoutingConstraints) Penalty(system *SpaceSystem) float64 {
+	total := 0.0
+
+	for name, penalty := range cons.Penalties {
+		if filter, exists := Filters[name]; exists && penalty > 0 && filter(system) {
+			total += penalty
+		}
+	}
+
+	return total
+}
+
+/**
+ * Returns true if the ship is allowed to enter the system.
+ */
+func (cons *RoutingConstraints) Permitted(system *SpaceSystem) bool {
+	return !system.NeedsPermit || cons.IgnorePermits || cons.Permits[system.ID]
+}
+
+/**
+ * Returns the name of the search algorithm that these constraints select.
+ */
+func (cons *RoutingConstraints) SearchMode() string {
+	if cons.Greedy {
+		return SearchGreedy
+	} else if cons.Weight > 1 {
+		return SearchWeighted
+	}
+
+	return SearchOptimal
+}
+
+/**
+ * Returns the size of the ship's fuel tank, in tons.
+ */
+func (cons *RoutingConstraints) Tank() float64 {
+	if cons.Ship != nil {
+		return cons.Ship.FuelCapacity
+	}
+
+	return cons.TankSize
+}
+
+/**
+ * Returns true if the search should keep track of the ship's fuel level.
+ */
+func (cons *RoutingConstraints) TracksFuel() bool {
+	return cons.Tank() > 0
+}
+
+/**
+ * Returns the amount of fuel the ship has when departing from the origin.
+ */
+func (cons *RoutingConstraints) InitialFuel() float64 {
+	if cons.StartFuel != nil && *cons.StartFuel < cons.Tank() {
+		return math.Max(*cons.StartFuel, 0)
+	}
+
+	return cons.Tank()
+}
+
+/**
+ * Returns the longest jump that can be made with the specified amount of fuel in the tank.
+ */
+func (cons *RoutingConstraints) JumpRange(fuel float64) float64 {
+	if cons.Ship != nil {
+		return cons.Ship.JumpRange(fuel)
+	}
+
+	return cons.MaxJump
+}
+
+/**
+ * Returns the longest jump that can ever be made, not counting supercharging. For ships this
+ * is with just enough fuel in the tank for one maximum range jump.
+ */
+func (cons *RoutingConstraints) MaxRange() float64 {
+	if cons.Ship != nil {
+		return cons.Ship.JumpRange(cons.Ship.MaxFuelPerJump)
+	}
+
+	return cons.MaxJump
+}
+
+/**
+ * Returns the amount of fuel needed to jump the specified distance with the specified amount
+ * of fuel in the tank.
+ */
+func (cons *RoutingConstraints) FuelForJump(distance float64, fuel float64) float64 {
+	if cons.Ship != nil {
+		return cons.Ship.FuelUse(distance, fuel)
+	}
+
+	return cons.FuelPerJump
+}
+
+/**
+ * Returns the jump range multiplier available when leaving the specified system.
+ */
+func (cons *RoutingConstraints) SuperchargeFactor(system *SpaceSystem) float64 {
+	if cons.Supercharge {
+		return system.SuperchargeFactor()
+	}
+
+	return 1
+}
+
+/**
+ * Returns true if the ship has to supercharge its FSD to jump between two systems, because the
+ * jump is further than it could go otherwise when leaving with the specified amount of fuel.
+ */
+func (cons *RoutingConstraints) NeedsSupercharge(from *SpaceSystem, to *SpaceSystem, fuel float64) bool {
+	return cons.SuperchargeFactor(from) > 1 && from.DistanceTo(to) > cons.JumpRange(fuel)
+}
+
+/**
+ * The standard cost of jumping directly between two systems. This is the distance between them
+ * unless we're supercharging, in which case each jump costs the same, or minimizing time, in
+ * which case it's how long the jump takes. Any penalty for entering the system we're jumping to
+ * is added on top.
+ */
+func (cons *RoutingConstraints) JumpCost(from *SpaceSystem, to *SpaceSystem) float64 {
+	if cons.MinimizeTime {
+		return cons.JumpSeconds(from, to) + cons.Penalty(to)
+	} else if cons.Supercharge {
+		return 1 + cons.Penalty(to)
+	}
+
+	return from.DistanceTo(to) + cons.Penalty(to)
+}
+
+/**
+ * Estimates how many seconds it takes to jump between two systems, including supercharging the
+ * FSD on the way out of `from` if the jump is too far without it. The fuel in the tank isn't
+ * known here, so that's judged on the range with a full tank.
+ */
+func (cons *RoutingConstraints) JumpSeconds(from *SpaceSystem, to *SpaceSystem) float64 {
+	return jumpSeconds(from.DistanceTo(to), cons.NeedsSupercharge(from, to, cons.Tank()))
+}
+
+func jumpSeconds(distance float64, supercharge bool) float64 {
+	seconds := JumpTime + TunnelTimePerLY*distance
+	if supercharge {
+		seconds += SuperchargeTime
+	}
+
+	return seconds
+}
+
+/**
+ * Estimates how many seconds it takes to fill the tank by scooping in the system, when arriving
+ * with the specified amount of fuel. This is zero if the scoop rate isn't known, fuel isn't
+ * being tracked, or there's no star to scoop from (see DockSeconds for that).
+ */
+func (cons *RoutingConstraints) ScoopSeconds(system *SpaceSystem, fuel float64) float64 {
+	if cons.ScoopRate <= 0 || !cons.TracksFuel() || !system.ContainsScoopableStar {
+		return 0
+	}
+
+	return math.Max(cons.Tank()-fuel, 0) / cons.ScoopRate
+}
+
+/**
+ * Estimates how many seconds it takes to supercruise to the closest station that can refuel the
+ * ship, in systems where it has to dock for fuel. This is zero if fuel isn't being tracked,
+ * there's a star to scoop from, or the system's stations aren't known.
+ */
+func (cons *RoutingConstraints) DockSeconds(system *SpaceSystem) float64 {
+	if !cons.TracksFuel() || system.ContainsScoopableStar {
+		return 0
+	}
+
+	return system.RefuelStation(cons.LandingPad()).SupercruiseTime()
+}
+
+/**
+ * Estimates how many seconds it takes to fly between the stops, including refuelling and
+ * supercharging along the way. Supercruising to stations that were asked for isn't included,
+ * since it depends on which stations the route picks.
+ */
+func (cons *RoutingConstraints) Duration(stops []*SpaceStop) float64 {
+	seconds := 0.0
+
+	for i := 1; i < len(stops); i++ {
+		prev := stops[i-1]
+		if prev.Refuel {
+			seconds += cons.ScoopSeconds(prev.System, prev.FuelRemaining) + cons.DockSeconds(prev.System)
+		}
+
+		seconds += jumpSeconds(stops[i].DistanceFromPrev, prev.Supercharge)
+	}
+
+	return seconds
+}
+
+/**
+ * Adds up the cost of flying between the stops the same way that FindPath does, including
+ * scooping or docking at any stops where the ship refuels.
+ */
+func (cons *RoutingConstraints) PathCost(stops []*SpaceStop) float64 {
+	cost := cons.EdgeCost()
+	total := 0.0
+
+	for i := 1; i < len(stops); i++ {
+		prev := stops[i-1]
+		if prev.Refuel {
+			total += cons.TimeCost(cons.ScoopSeconds(prev.System, prev.FuelRemaining) + cons.DockSeconds(prev.System))
+		}
+
+		total += cost.JumpCost(prev.System, stops[i].System)
+	}
+
+	return total
+}
+
+/**
+ * Converts seconds spent outside of hyperspace (supercruising or scooping) into the same units
+ * as the cost of a jump. When minimizing time that's just the seconds; otherwise it's worked out
+ * from how far the ship could have jumped in that time instead.
+ */
+func (cons *RoutingConstraints) TimeCost(seconds float64) float64 {
+	if cons.MinimizeTime {
+		return seconds
+	} else if cons.Supercharge {
+		return seconds / JumpTime
+	}
+
+	return seconds / JumpTime * cons.MaxRange()
+}
+
+/**
+ * Estimates the cost of getting from one system to another. This never overestimates, so when
+ * supercharging it assumes every remaining jump could be boosted by a neutron star.
+ */
+func (cons *RoutingConstraints) Estimate(from *SpaceSystem, to *SpaceSystem) float64 {
+	if !cons.Supercharge && !cons.MinimizeTime {
+		return TravelCost(from, to)
+	}
+
+	reach := cons.MaxRange()
+	if cons.Supercharge {
+		reach *= NeutronSupercharge
+	}
+
+	// The fewest jumps it could possibly take; not rounded up since range can't be known exactly.
+	jumps := 0.0
+	if reach > 0 {
+		jumps = TravelCost(from, to) / reach
+	}
+
+	if cons.MinimizeTime {
+		return jumps*JumpTime + TravelCost(from, to)*TunnelTimePerLY
+	}
+
+	return jumps
 }
 
 type SpaceRoute struct {
@@ -28,247 +415,427 @@ type SpaceRoute struct {
 	Destination *SpaceStop
 	Stops       []*SpaceStop
 	Distance    float64
+	Cost        float64 // total cost of the route, as measured by RoutingConstraints.EdgeCost
+	Duration    float64 // estimated number of seconds it takes to fly the route
+	Mode        string  // search algorithm used to find the route
 
 	// Debug info, probably to be removed
 	Checks int // number of sites that needed to be checked. fewer is faster.
 }
 
+// Rough number of seconds each part of a jump takes: charging the FSD, the hyperspace tunnel, and
+// turning away from the arrival star to line up the next jump. Longer jumps spend a little longer
+// in the tunnel, and supercharging means flying into the jet cone first.
 const (
-	UniverseMin = -16899.750
-	UniverseMax = 65630.156
+	ChargeTime      = 20.0
+	TunnelTime      = 14.0
+	TunnelTimePerLY = 0.05
+	AlignTime       = 11.0
+	SuperchargeTime = 40.0
 )
 
+// Rough number of seconds it takes to make a single jump, including charging the FSD.
+const JumpTime = ChargeTime + TunnelTime + AlignTime
+
+// Number of systems FindPath expands between checks for cancellation.
+const cancelCheckInterval = 64
+
+/**
+ * SpaceGraph holds every known system. Systems are stored in a sparse grid of cubic buckets,
+ * each Radius light years wide, and spatial queries go through a SpatialIndex, which is the
+ * grid unless another index is provided with UseIndex().
+ */
 type SpaceGraph struct {
-	Buckets [][][]*SpaceBucket
 	Radius  float64
+	grid    *SpaceGrid
+	index   SpatialIndex
 	systems map[SystemID]*SpaceSystem // access via Get()
+	version int                       // incremented whenever the graph changes
+
+	// Bounding box of all systems in the graph.
+	min, max [3]float64
 }
 
 /**
  * Identifies the bucket coordinates of the given system.
  */
 func (graph *SpaceGraph) FindBucket(system *SpaceSystem) (SystemID, SystemID, SystemID) {
-	bucketSize := math.Ceil((UniverseMax - UniverseMin) / float64(len(graph.Buckets)))
+	return graph.grid.FindBucket(system)
+}
 
-	x := int(math.Floor((system.X - UniverseMin) / bucketSize))
-	y := int(math.Floor((system.Y - UniverseMin) / bucketSize))
-	z := int(math.Floor((system.Z - UniverseMin) / bucketSize))
+/**
+ * Finds the cheapest route between two systems that satisfies the constraints. If there isn't
+ * one, the error is a *LegError that wraps one of the Err* values from errors.go, or the
+ * context's error if the search was cancelled. Avoided systems, custom filters and missing
+ * permits don't get the blame for there not being a route, apart from a permit for the
+ * destination itself; use BlameRestrictions to find out whether they're why.
+ */
+func (graph *SpaceGraph) FindPath(ctx context.Context, from *SpaceSystem, to *SpaceSystem, cons *RoutingConstraints) (*SpaceRoute, error) {
+	fail := func(err error) (*SpaceRoute, error) {
+		return nil, legError(from, to, err)
+	}
 
-	return SystemID(x), SystemID(y), SystemID(z)
-}
+	for _, system := range []*SpaceSystem{from, to} {
+		if err := graph.Contains(system); err != nil {
+			return fail(err)
+		}
+	}
+
+	// We're already in the origin, but can't get into the destination without a permit.
+	if !cons.Permitted(to) {
+		return fail(ErrPermitRequired)
+	}
+
+	// Keep track of why routes were abandoned so we can explain why there isn't one.
+	hopLimited := false
+	outOfFuel := false
 
-func (graph *SpaceGraph) FindPath(from *SpaceSystem, to *SpaceSystem, cons *RoutingConstraints) *SpaceRoute {
-	visited := make(map[*SpaceSystem]bool)
-	queued := make(map[*SpaceSystem]bool)
-	path := make(map[*SpaceSystem]*SpaceSystem)
+	// Systems that have already been expanded, along with the amount of fuel we had when we got
+	// there. A system can be expanded again if we find a way to reach it with more fuel.
+	expanded := make(map[*SpaceSystem]float64)
+	cost, filter, reach, hops := cons.EdgeCost(), cons.EdgeFilter(), cons.JumpReach(), cons.HopLimit()
 	available := NewDestinationQueue(to)
+	available.heuristic = cons.Estimator().Estimate
+	available.greedy = cons.Greedy
+	if cons.Weight > 1 {
+		available.weight = cons.Weight
+	}
 
-	heap.Push(&available, &SearchStop{Location: from, Hops: 0})
-	queued[from] = true
+	// Stops that are waiting to be expanded in each system. A cheaper way of getting somewhere
+	// isn't always better, since it might leave us with less fuel, so every stop that isn't beaten
+	// on both cost and fuel by another one in the same system is kept.
+	queued := make(map[*SpaceSystem][]*SearchStop)
+	queue := func(stop *SearchStop) {
+		for _, other := range queued[stop.Location] {
+			if other.Cost <= stop.Cost && other.Fuel >= stop.Fuel {
+				return
+			}
+		}
+
+		kept := queued[stop.Location][:0]
+		for _, other := range queued[stop.Location] {
+			if stop.Cost <= other.Cost && stop.Fuel >= other.Fuel {
+				other.dropped = true
+			} else {
+				kept = append(kept, other)
+			}
+		}
 
-	costFromOrigin := make(map[*SpaceSystem]float64)    // known
-	costToDestination := make(map[*SpaceSystem]float64) // blend of known and heuristic
+		queued[stop.Location] = append(kept, stop)
+		heap.Push(&available, stop)
+	}
 
-	costFromOrigin[from] = 0.0
-	costToDestination[from] = TravelCost(from, to)
+	queue(&SearchStop{Location: from, Hops: 0, Fuel: cons.InitialFuel()})
 
 	checks := 0
 	// Based on A* pseudocode from Wikipedia:
 	//    https://en.wikipedia.org/wiki/A*_search_algorithm#Pseudocode\
 	for available.Len() > 0 {
 		current := heap.Pop(&available).(*SearchStop)
+		if current.dropped {
+			continue
+		}
+
+		kept := queued[current.Location][:0]
+		for _, other := range queued[current.Location] {
+			if other != current {
+				kept = append(kept, other)
+			}
+		}
+
+		queued[current.Location] = kept
 
 		// If we've exceeded the maximum number of hops, abandon this route and move on
 		// to the next.
-		if current.Hops > cons.MaxHops {
+		if !hops.AllowsHops(current.Hops) {
+			hopLimited = true
 			continue
 		}
 
-		checks++
-		visited[current.Location] = true // mark the current location as visited
+		// Skip this stop if we've already been here with at least as much fuel in the tank.
+		if fuel, seen := expanded[current.Location]; seen && fuel >= current.Fuel {
+			continue
+		}
 
-		// Return success! We've reached our destination
-		if current.Location.ID == to.ID {
-			var unwound []*SpaceStop
-			var prev *SpaceSystem
-			current := to
+		// Give up if we've used up our budget or nobody is waiting for the result anymore.
+		if cons.MaxExpansions > 0 && checks >= cons.MaxExpansions {
+			return fail(ErrBudgetExhausted)
+		}
 
-			for current != nil {
-				stop := current.AsStop()
-				unwound = append([]*SpaceStop{stop}, unwound...)
+		if checks%cancelCheckInterval == 0 && ctx.Err() != nil {
+			return fail(ctx.Err())
+		}
 
-				prev = current
-				current = path[current]
+		checks++
+		expanded[current.Location] = current.Fuel // mark the current location as visited
 
-				if current != nil {
-					stop.DistanceFromPrev = current.DistanceTo(prev)
-				}
-			}
+		// Return success! We've reached our destination
+		if current.Location.ID == to.ID {
+			unwound := current.Unwind(cons)
 
 			distance := 0.0
 			for i := 1; i < len(unwound); i++ {
 				distance += unwound[i].DistanceFromPrev
-				// distance += unwound[i].DistanceTo(unwound[i-1])
 			}
 
 			return &SpaceRoute{
 				Origin:      from.AsStop(),
 				Destination: to.AsStop(),
 				Distance:    distance,
+				Cost:        current.Cost,
+				Duration:    cons.Duration(unwound),
+				Mode:        cons.SearchMode(),
 				Stops:       unwound,
 				Checks:      checks,
-			}
+			}, nil
 		}
 
-		// Investigate each neighbor if they haven't been investigated yet (if they have then we already found a
-		// shorter way to get there and a loop isn't going to help).
-		for _, near := range graph.Proximity(current.Location, cons.MaxJump) {
-			if !visited[near] {
-				score := costFromOrigin[current.Location] + current.Location.DistanceTo(near)
-
-				// If its not already being searched, add it to the queue
-				if !queued[near] {
-					heap.Push(&available, &SearchStop{
-						Location: near,
-						Hops:     current.Hops + 1,
-					})
-					queued[near] = true
-				} else if score >= costFromOrigin[near] {
+		// Refuelling takes time, so it's only ever an option: the ship can also move on with
+		// whatever's left in the tank. Refuelling is planned by leaving from a copy of the stop
+		// that tops up the tank first.
+		departures := []*SearchStop{current}
+		if cons.TracksFuel() && cons.CanRefuel(current.Location) && current.Fuel < cons.Tank() {
+			refuelled := *current
+			refuelled.Refuel = true
+			refuelled.Cost += cons.TimeCost(cons.ScoopSeconds(current.Location, current.Fuel) + cons.DockSeconds(current.Location))
+			departures = append(departures, &refuelled)
+		}
+
+		// Supercharged jumps cover more distance for the same amount of fuel.
+		boost := cons.SuperchargeFactor(current.Location)
+
+		for _, departure := range departures {
+			// The amount of fuel we're leaving with determines how far we can jump.
+			fuel := departure.Fuel
+			if departure.Refuel {
+				fuel = cons.Tank()
+			}
+
+			// Investigate each neighbor if they haven't been investigated yet (if they have then we already found a
+			// shorter way to get there and a loop isn't going to help, unless we'd arrive with more fuel).
+			for _, near := range graph.Proximity(current.Location, reach.Reach(current.Location, fuel)) {
+				if cons.blocked.prevents(current.Location, near) {
+					continue
+				}
+
+				if near != to && !filter.CanJump(current.Location, near) {
 					continue
 				}
 
-				path[near] = current.Location
-				costFromOrigin[near] = score
-				costToDestination[near] = costFromOrigin[current.Location] + TravelCost(current.Location, to)
+				remaining := fuel
+				if cons.TracksFuel() {
+					remaining -= cons.FuelForJump(current.Location.DistanceTo(near)/boost, fuel)
+
+					// Not enough fuel to make the jump. That's only to blame for a missing route if
+					// it's still true after refuelling, which is always the last option.
+					if remaining < 0 {
+						outOfFuel = outOfFuel || departure == departures[len(departures)-1]
+						continue
+					}
+				}
+
+				if prev, seen := expanded[near]; seen && prev >= remaining {
+					continue
+				}
+
+				queue(&SearchStop{
+					Location: near,
+					Hops:     current.Hops + 1,
+					Cost:     departure.Cost + cost.JumpCost(current.Location, near),
+					Fuel:     remaining,
+					Prev:     departure,
+				})
 			}
 		}
 	}
 
-	return nil
+	if hopLimited {
+		return fail(ErrHopLimit)
+	} else if outOfFuel {
+		return fail(ErrOutOfFuel)
+	}
+
+	return fail(ErrUnreachable)
 }
 
 /**
- * Return pointers to all SpaceSystem's within the specified radius of the origin. The origin currently needs
- * to be a SpaceSystem but this could conceivably work with any point.
+ * Works out whether avoided systems, a custom filter or missing permits are the reason a search
+ * didn't find a route, by searching the same leg again without them. If they are, the error is
+ * replaced with ErrAvoided, ErrFiltered or ErrPermitRequired for the leg; otherwise (or if the
+ * error isn't about a missing route) it's returned as it is. Restrictions are lifted in that
+ * order, so each one only gets the blame if it's still in the way without the ones before it.
  *
- * This currently only looks through the same bucket as the origin system. Need to expand that.
+ * This can take a couple of searches as long as the original one, so it's best done once for a
+ * request that failed rather than for every search that's part of it.
  */
-func (graph *SpaceGraph) Proximity(origin *SpaceSystem, radius float64) []*SpaceSystem {
-	var items []*SpaceSystem
-
-	// Get all nearby buckets (including the current one) and scan all systems in each bucket.
-	buckets := graph.NearbyBuckets(origin, radius)
-	for _, bucket := range buckets {
-		for _, loc := range bucket.Systems {
-			if origin.DistanceTo(loc) < radius {
-				items = append(items, loc)
-			}
+func (graph *SpaceGraph) BlameRestrictions(ctx context.Context, err error, cons *RoutingConstraints) error {
+	var leg *LegError
+	if !errors.As(err, &leg) || !(errors.Is(err, ErrUnreachable) || errors.Is(err, ErrHopLimit) || errors.Is(err, ErrOutOfFuel)) {
+		return err
+	}
+
+	from, to := graph.Get(leg.From), graph.Get(leg.To)
+	if from == nil || to == nil {
+		return err
+	}
+
+	relaxed := *cons
+	if len(cons.AvoidSystems) > 0 || len(cons.AvoidRegions) > 0 || len(cons.AvoidTypes) > 0 || cons.AvoidHazards {
+		relaxed.AvoidSystems = nil
+		relaxed.AvoidRegions = nil
+		relaxed.AvoidTypes = nil
+		relaxed.AvoidHazards = false
+
+		if _, retry := graph.FindPath(ctx, from, to, &relaxed); retry == nil {
+			return legError(from, to, ErrAvoided)
 		}
 	}
 
-	return items
+	if cons.Filter != nil {
+		relaxed.Filter = nil
+
+		if _, retry := graph.FindPath(ctx, from, to, &relaxed); retry == nil {
+			return legError(from, to, ErrFiltered)
+		}
+	}
+
+	if !cons.IgnorePermits {
+		relaxed.IgnorePermits = true
+
+		if _, retry := graph.FindPath(ctx, from, to, &relaxed); retry == nil {
+			return legError(from, to, ErrPermitRequired)
+		}
+	}
+
+	return err
+}
+
+/**
+ * Return pointers to all SpaceSystem's within the specified radius of the origin. The origin currently needs
+ * to be a SpaceSystem but this could conceivably work with any point.
+ */
+func (graph *SpaceGraph) Proximity(origin *SpaceSystem, radius float64) []*SpaceSystem {
+	return graph.index.Within(origin, radius)
+}
+
+/**
+ * Returns the k systems closest to the origin, closest first. The origin doesn't need to be part
+ * of the graph, so this also works for finding the systems closest to arbitrary coordinates.
+ */
+func (graph *SpaceGraph) Nearest(origin *SpaceSystem, k int) []*SpaceSystem {
+	return graph.index.Nearest(origin, k)
+}
+
+/**
+ * Returns all systems inside the box with corners at low and high.
+ */
+func (graph *SpaceGraph) InBox(low *SpaceSystem, high *SpaceSystem) []*SpaceSystem {
+	return graph.index.InBox(low, high)
 }
 
 /**
  * Return all buckets within the radius of the origin, including the bucket the origin is currently in.
- * It should only return each bucket one time, and buckets will be pointers to the actual buckets.
  */
 func (graph *SpaceGraph) NearbyBuckets(origin *SpaceSystem, radius float64) []*SpaceBucket {
-	start := graph.GetBucket(graph.FindBucket(origin))
-	buckets := []*SpaceBucket{start}
-
-	// Get the bucket in the -x direction
-	xDown := graph.GetBucket(graph.FindBucket(&SpaceSystem{
-		X: origin.X - radius,
-		Y: origin.Y,
-		Z: origin.Z,
-	}))
+	return graph.grid.NearbyBuckets(origin, radius)
+}
 
-	if xDown != start {
-		buckets = append(buckets, xDown)
-	}
+/**
+ * Simple function to provide an abstraction over the bucket storage schema. Returns nil if there
+ * aren't any systems in the bucket.
+ */
+func (graph *SpaceGraph) GetBucket(x SystemID, y SystemID, z SystemID) *SpaceBucket {
+	return graph.grid.GetBucket(x, y, z)
+}
 
-	// Get the bucket in the +x direction
-	xUp := graph.GetBucket(graph.FindBucket(&SpaceSystem{
-		X: origin.X + radius,
-		Y: origin.Y,
-		Z: origin.Z,
-	}))
+/**
+ * Returns the number of buckets that contain systems.
+ */
+func (graph *SpaceGraph) BucketCount() int {
+	return graph.grid.BucketCount()
+}
 
-	if xUp != start {
-		buckets = append(buckets, xUp)
+/**
+ * Switches spatial queries over to a different index. Systems that are already in the graph are
+ * added to the new index.
+ */
+func (graph *SpaceGraph) UseIndex(index SpatialIndex) {
+	for _, system := range graph.systems {
+		index.Insert(system)
 	}
 
-	// Get the bucket in the -y direction
-	yDown := graph.GetBucket(graph.FindBucket(&SpaceSystem{
-		X: origin.X,
-		Y: origin.Y - radius,
-		Z: origin.Z,
-	}))
+	graph.index = index
+}
 
-	if yDown != start {
-		buckets = append(buckets, yDown)
+/**
+ * Returns true if the system is within the bounding box of all systems in the graph.
+ */
+func (graph *SpaceGraph) InBounds(system *SpaceSystem) bool {
+	if len(graph.systems) == 0 {
+		return false
 	}
 
-	// Get the bucket in the +y direction
-	yUp := graph.GetBucket(graph.FindBucket(&SpaceSystem{
-		X: origin.X,
-		Y: origin.Y + radius,
-		Z: origin.Z,
-	}))
+	return system.X <= graph.max[0] && system.X >= graph.min[0] &&
+		system.Y <= graph.max[1] && system.Y >= graph.min[1] &&
+		system.Z <= graph.max[2] && system.Z >= graph.min[2]
+}
 
-	if yUp != start {
-		buckets = append(buckets, yUp)
+/**
+ * Returns an error if the system isn't part of the graph: ErrOutOfBounds if it's outside of the
+ * area that the graph covers, and ErrUnknownSystem otherwise.
+ */
+func (graph *SpaceGraph) Contains(system *SpaceSystem) error {
+	if system == nil {
+		return ErrUnknownSystem
+	} else if !graph.InBounds(system) {
+		return ErrOutOfBounds
+	} else if graph.Get(system.ID) != system {
+		return ErrUnknownSystem
 	}
 
-	// Get the bucket in the -z direction
-	zDown := graph.GetBucket(graph.FindBucket(&SpaceSystem{
-		X: origin.X,
-		Y: origin.Y,
-		Z: origin.Z - radius,
-	}))
+	return nil
+}
 
-	if zDown != start {
-		buckets = append(buckets, zDown)
+func (graph *SpaceGraph) Add(system *SpaceSystem) error {
+	if math.IsNaN(system.X) || math.IsNaN(system.Y) || math.IsNaN(system.Z) {
+		return fmt.Errorf("system %s: %w", system.Name, ErrOutOfBounds)
 	}
 
-	// Get the bucket in the +z direction
-	zUp := graph.GetBucket(graph.FindBucket(&SpaceSystem{
-		X: origin.X,
-		Y: origin.Y,
-		Z: origin.Z + radius,
-	}))
-
-	if zUp != start {
-		buckets = append(buckets, zUp)
+	graph.grid.Insert(system)
+	if graph.index != SpatialIndex(graph.grid) {
+		graph.index.Insert(system)
 	}
 
-	return buckets
+	graph.extend(system)
+	graph.systems[system.ID] = system
+	graph.version++
+
+	return nil
 }
 
 /**
- * Simple function to provide an abstraction over the bucket storage schema.
+ * Grows the bounding box to include the system.
  */
-func (graph *SpaceGraph) GetBucket(x SystemID, y SystemID, z SystemID) *SpaceBucket {
-	return graph.Buckets[x][y][z]
-}
+func (graph *SpaceGraph) extend(system *SpaceSystem) {
+	coords := [3]float64{system.X, system.Y, system.Z}
 
-func (graph *SpaceGraph) Add(system *SpaceSystem) error {
-	if system.X > UniverseMax || system.X < UniverseMin ||
-		system.Y > UniverseMax || system.Y < UniverseMin ||
-		system.Z > UniverseMax || system.Z < UniverseMin {
-		return errors.New("System " + system.Name + " out of currently supported bounds.")
-	}
-	// Find coordinates for this system
-	x, y, z := graph.FindBucket(system)
-
-	// fmt.Printf("adding to (%d, %d, %d)\n", x, y, z)
-	// Add to the cell's buckets
-	system.Bucket = graph.GetBucket(x, y, z)
-	system.Bucket.Systems = append(system.Bucket.Systems, system)
-	graph.systems[system.ID] = system
+	for axis := range coords {
+		if len(graph.systems) == 0 || coords[axis] < graph.min[axis] {
+			graph.min[axis] = coords[axis]
+		}
 
-	return nil
+		if len(graph.systems) == 0 || coords[axis] > graph.max[axis] {
+			graph.max[axis] = coords[axis]
+		}
+	}
+}
+
+/**
+ * Returns a number that changes every time systems are added to the graph. Anything derived from
+ * the graph (like cached routes) is out of date if the version has changed.
+ */
+func (graph *SpaceGraph) Version() int {
+	return graph.version
 }
 
 func (graph *SpaceGraph) Get(id SystemID) *SpaceSystem {
@@ -301,31 +868,11 @@ func (graph *SpaceGraph) GetRandom() *SpaceSystem {
 func InitGraph(radius float64) *SpaceGraph {
 	graph := new(SpaceGraph)
 	graph.Radius = radius
+	graph.grid = NewSpaceGrid(radius)
+	graph.index = graph.grid
 	graph.systems = make(map[SystemID]*SpaceSystem)
 
-	count := int(math.Ceil((UniverseMax - UniverseMin) / radius))
-	fmt.Printf("Initializing graph (%d^3)...\n", count)
-	graph.Buckets = make([][][]*SpaceBucket, count)
-
-	// Make all the space buckets!
-	for i := 0; i < count; i++ {
-		graph.Buckets[i] = make([][]*SpaceBucket, count)
-
-		for j := 0; j < count; j++ {
-			graph.Buckets[i][j] = make([]*SpaceBucket, 0, count)
-
-			for k := 0; k < count; k++ {
-				bucket := &SpaceBucket{
-					X:       i,
-					Y:       j,
-					Z:       k,
-					Systems: make([]*SpaceSystem, 0),
-				}
-
-				graph.Buckets[i][j] = append(graph.Buckets[i][j], bucket)
-			}
-		}
-	}
+	fmt.Printf("Initializing graph (%v LY cells)...\n", radius)
 
 	return graph
 }
@@ -340,7 +887,7 @@ func (graph *SpaceGraph) Load(db *SpaceDB) *SpaceGraph {
 		count++
 	})
 
-	fmt.Printf("Loaded %d systems.\n", count)
+	fmt.Printf("Loaded %d systems into %d cells.\n", count, graph.BucketCount())
 
 	return graph
 }
@@ -365,5 +912,37 @@ func (route *SpaceRoute) Merge(next *SpaceRoute) {
 	route.Destination = next.Destination
 
 	route.Distance += next.Distance
+	route.Cost += next.Cost
+	route.Duration += next.Duration
 	route.Checks += next.Checks
 }
+
+/**
+ * Returns a copy of the route that can be modified without affecting the original. Systems are
+ * shared between both routes.
+ */
+func (route *SpaceRoute) Copy() *SpaceRoute {
+	if route == nil {
+		return nil
+	}
+
+	copied := *route
+	copied.Stops = make([]*SpaceStop, len(route.Stops))
+
+	for i, stop := range route.Stops {
+		next := *stop
+		copied.Stops[i] = &next
+	}
+
+	if route.Origin != nil {
+		origin := *route.Origin
+		copied.Origin = &origin
+	}
+
+	if route.Destination != nil {
+		destination := *route.Destination
+		copied.Destination = &destination
+	}
+
+	return &copied
+}
@@ -1,16 +1,18 @@
 package structs
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"structs"
+	"math/rand"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 )
 
-func BenchmarkLoad(t *testing.T) {
-	db := structs.Connect("sample")
-	db.ForEachSystem(func(system SpaceSystem) {})
+func BenchmarkLoad(b *testing.B) {
+	db := Connect("sample")
+	db.ForEachSystem(func(system *SpaceSystem) {})
 }
 
 func TestRoute(t *testing.T) {
@@ -21,7 +23,7 @@ func TestRoute(t *testing.T) {
 
 	fmt.Printf("Seeking path from:\n  %+v\nto:\n  %+v\n", from, to)
 
-	path := graph.FindPath(from, to, &RoutingConstraints{
+	path, _ := graph.FindPath(context.Background(), from, to, &RoutingConstraints{
 		MaxHops: 5,
 		MaxJump: 5,
 	})
@@ -41,7 +43,7 @@ func TestRoute(t *testing.T) {
 
 func BenchmarkFindPath(b *testing.B) {
 	b.StopTimer()
-	db := Connect()
+	db := Connect("sample")
 	graph := InitGraph(1000).Load(db)
 
 	for i := 0; i < b.N; i++ {
@@ -52,9 +54,597 @@ func BenchmarkFindPath(b *testing.B) {
 
 		// Run the search
 		b.StartTimer()
-		graph.FindPath(start, end, &RoutingConstraints{
+		graph.FindPath(context.Background(), start, end, &RoutingConstraints{
 			MaxHops: 8,
 			MaxJump: 15,
 		})
 	}
 }
+
+func TestRouteRefuels(t *testing.T) {
+	graph := InitGraph(1000)
+	graph.Add(&SpaceSystem{ID: 1, Name: "Origin", X: 0, Y: 0, Z: 0})
+	graph.Add(&SpaceSystem{ID: 2, Name: "Dry Site", X: 4, Y: 0, Z: 0})
+	graph.Add(&SpaceSystem{ID: 3, Name: "Scoop Site", X: 3.5, Y: 2, Z: 0, ContainsScoopableStar: true})
+	graph.Add(&SpaceSystem{ID: 4, Name: "Destination", X: 7, Y: 0, Z: 0})
+
+	// Only enough fuel for two jumps, so the route needs to detour through the scoopable star
+	// instead of heading straight through the dry system.
+	path, ids, _ := routeIDs(t, graph, 1, 4, &RoutingConstraints{
+		MaxHops:     5,
+		MaxJump:     5,
+		TankSize:    2,
+		FuelPerJump: 1,
+		StartFuel:   tons(1),
+	})
+
+	if assert.NotNil(t, path, "no path found") {
+		assert.Equal(t, []SystemID{1, 3, 4}, ids, "route should pass through the scoopable star")
+		assert.True(t, path.Stops[1].Refuel, "should refuel at the scoopable star")
+		assert.Equal(t, 0.0, path.Stops[1].FuelRemaining)
+		assert.Equal(t, 1.0, path.Stops[2].FuelRemaining)
+	}
+}
+
+func TestRouteFuelTradeoffs(t *testing.T) {
+	graph := InitGraph(1000)
+	graph.Add(&SpaceSystem{ID: 1, Name: "Origin", X: 0, Y: 0, Z: 0})
+	graph.Add(&SpaceSystem{ID: 2, Name: "Cheap Step", X: 1, Y: 0, Z: 0})
+	graph.Add(&SpaceSystem{ID: 3, Name: "Another Cheap Step", X: 1, Y: 1, Z: 0})
+	graph.Add(&SpaceSystem{ID: 4, Name: "Middle Step", X: 0, Y: 1, Z: 0})
+	graph.Add(&SpaceSystem{ID: 5, Name: "Station", X: 0, Y: -1, Z: 0, ContainsRefuelStation: true})
+	graph.Add(&SpaceSystem{ID: 6, Name: "Junction", X: 3, Y: 0, Z: 0})
+	graph.Add(&SpaceSystem{ID: 7, Name: "Destination", X: 7, Y: 0, Z: 0})
+
+	// There are three ways to the junction: the cheapest runs the tank dry, the priciest refuels
+	// on the way, and the one in the middle leaves just enough fuel for the last jump. It isn't
+	// beaten by either of the others on both counts, so it needs to be kept around.
+	costs := map[[2]SystemID]float64{
+		{1, 2}: 1, {2, 3}: 1, {3, 6}: 1,
+		{1, 4}: 3, {4, 6}: 3,
+		{1, 5}: 1, {5, 6}: 10,
+		{6, 7}: 1,
+	}
+
+	path, ids, _ := routeIDs(t, graph, 1, 7, &RoutingConstraints{
+		MaxHops:     10,
+		MaxJump:     5,
+		TankSize:    3,
+		FuelPerJump: 1,
+		Cost:        EdgeCostFunc(func(from *SpaceSystem, to *SpaceSystem) float64 { return costs[[2]SystemID{from.ID, to.ID}] }),
+		Filter:      EdgeFilterFunc(func(from *SpaceSystem, to *SpaceSystem) bool { return costs[[2]SystemID{from.ID, to.ID}] > 0 }),
+		Heuristic:   HeuristicFunc(func(from *SpaceSystem, to *SpaceSystem) float64 { return 0 }),
+	})
+
+	if assert.NotNil(t, path, "no path found") {
+		assert.Equal(t, []SystemID{1, 4, 6, 7}, ids)
+		assert.Equal(t, 7.0, path.Cost)
+	}
+}
+
+func TestRouteRefuelPadSize(t *testing.T) {
+	graph := InitGraph(1000)
+	graph.Add(&SpaceSystem{ID: 1, Name: "Origin", X: 0, Y: 0, Z: 0})
+	graph.Add(&SpaceSystem{ID: 2, Name: "Outpost", X: 4, Y: 0, Z: 0, ContainsRefuelStation: true, RefuelPadSize: "M"})
+	graph.Add(&SpaceSystem{ID: 3, Name: "Scoop Site", X: 3.5, Y: 2, Z: 0, ContainsScoopableStar: true})
+	graph.Add(&SpaceSystem{ID: 4, Name: "Destination", X: 7, Y: 0, Z: 0})
+
+	for pad, expected := range map[string][]SystemID{"": {1, 2, 4}, "M": {1, 2, 4}, "L": {1, 3, 4}} {
+		_, ids, _ := routeIDs(t, graph, 1, 4, &RoutingConstraints{MaxHops: 5, MaxJump: 5, TankSize: 2, FuelPerJump: 1, StartFuel: tons(1), PadSize: pad})
+		assert.Equal(t, expected, ids, "pad size %q", pad)
+	}
+
+	// The ship's pad size is used unless the constraints say otherwise.
+	ship := Ships["anaconda"]
+	assert.Equal(t, "L", (&RoutingConstraints{Ship: &ship}).LandingPad())
+	assert.Equal(t, "M", (&RoutingConstraints{Ship: &ship, PadSize: "M"}).LandingPad())
+}
+
+func TestRouteDockingTime(t *testing.T) {
+	outpost := &SpaceStation{ID: 1, DistanceToStar: 100, MaxLandingPad: "M", HasRefuel: true}
+	starport := &SpaceStation{ID: 2, DistanceToStar: 500000, MaxLandingPad: "L", HasRefuel: true}
+	nearby := &SpaceStation{ID: 3, DistanceToStar: 2000, MaxLandingPad: "L", HasRefuel: true}
+
+	graph := InitGraph(1000)
+	graph.Add(&SpaceSystem{ID: 1, Name: "Origin", X: 0, Y: 0, Z: 0})
+	graph.Add(&SpaceSystem{ID: 2, Name: "Nearby Starport", X: 4, Y: 0, Z: 0, ContainsRefuelStation: true, RefuelPadSize: "L", RefuelStations: []*SpaceStation{nearby}})
+	graph.Add(&SpaceSystem{ID: 3, Name: "Outpost", X: 3.5, Y: 2, Z: 0, ContainsRefuelStation: true, RefuelPadSize: "L", RefuelStations: []*SpaceStation{starport, outpost}})
+	graph.Add(&SpaceSystem{ID: 4, Name: "Destination", X: 7, Y: 0, Z: 0})
+
+	// The outpost is a quick detour for ships that fit, but big ships have to supercruise a long
+	// way out to the starport, so they're better off going straight through.
+	for pad, via := range map[string]SystemID{"": 3, "M": 3, "L": 2} {
+		path, ids, _ := routeIDs(t, graph, 1, 4, &RoutingConstraints{MaxHops: 5, MaxJump: 5, TankSize: 2, FuelPerJump: 1, StartFuel: tons(1), PadSize: pad, MinimizeTime: true})
+		assert.Equal(t, []SystemID{1, via, 4}, ids, "pad size %q", pad)
+
+		if assert.NotNil(t, path, "no path found for pad size %q", pad) {
+			docking := graph.Get(via).RefuelStation(pad).SupercruiseTime()
+			assert.True(t, path.Stops[1].Refuel)
+			assert.InDelta(t, 2*JumpTime+path.Distance*TunnelTimePerLY+docking, path.Duration, 0.001, "pad size %q", pad)
+			assert.InDelta(t, path.Duration, path.Cost, 0.001, "pad size %q", pad)
+		}
+	}
+
+	assert.Equal(t, outpost, graph.Get(3).RefuelStation("M"))
+	assert.Equal(t, starport, graph.Get(3).RefuelStation("L"))
+	assert.Nil(t, graph.Get(1).RefuelStation(""))
+}
+
+func TestRouteOutOfFuel(t *testing.T) {
+	graph := InitGraph(1000)
+	graph.Add(&SpaceSystem{ID: 1, Name: "Origin", X: 0, Y: 0, Z: 0})
+	graph.Add(&SpaceSystem{ID: 2, Name: "Dry Site", X: 4, Y: 0, Z: 0})
+	graph.Add(&SpaceSystem{ID: 3, Name: "Destination", X: 8, Y: 0, Z: 0})
+
+	path, err := graph.FindPath(context.Background(), graph.Get(1), graph.Get(3), &RoutingConstraints{
+		MaxHops:     5,
+		MaxJump:     5,
+		TankSize:    1,
+		FuelPerJump: 1,
+	})
+
+	assert.Nil(t, path, "shouldn't find a route that runs out of fuel")
+	assert.True(t, errors.Is(err, ErrOutOfFuel))
+}
+
+func TestRouteSupercharge(t *testing.T) {
+	graph := InitGraph(1000)
+	graph.Add(&SpaceSystem{ID: 1, Name: "Origin", X: 0, Y: 0, Z: 0})
+	graph.Add(&SpaceSystem{ID: 2, Name: "Neutron Site", X: 4, Y: 0, Z: 0, ArrivalNeutronStar: true})
+	graph.Add(&SpaceSystem{ID: 3, Name: "First Step", X: 8, Y: 0, Z: 0})
+	graph.Add(&SpaceSystem{ID: 4, Name: "Second Step", X: 12, Y: 0, Z: 0})
+	graph.Add(&SpaceSystem{ID: 5, Name: "Third Step", X: 16, Y: 0, Z: 0})
+	graph.Add(&SpaceSystem{ID: 6, Name: "Fourth Step", X: 20, Y: 0, Z: 0})
+	graph.Add(&SpaceSystem{ID: 7, Name: "Destination", X: 22, Y: 0, Z: 0})
+
+	cons := &RoutingConstraints{MaxHops: 10, MaxJump: 5}
+
+	path, _ := graph.FindPath(context.Background(), graph.Get(1), graph.Get(7), cons)
+	if assert.NotNil(t, path, "no path found") {
+		assert.Equal(t, 7, len(path.Stops), "should take the short jumps without supercharging")
+	}
+
+	cons.Supercharge = true
+	path, _ = graph.FindPath(context.Background(), graph.Get(1), graph.Get(7), cons)
+	if assert.NotNil(t, path, "no supercharged path found") {
+		assert.Equal(t, 3, len(path.Stops), "should jump straight from the neutron star")
+		assert.True(t, path.Stops[1].Supercharge)
+		assert.False(t, path.Stops[2].Supercharge)
+	}
+
+	// There's no need to supercharge for a jump that's in range anyway.
+	path, _ = graph.FindPath(context.Background(), graph.Get(1), graph.Get(3), cons)
+	if assert.NotNil(t, path, "no supercharged path found") {
+		assert.Equal(t, 3, len(path.Stops))
+		assert.False(t, path.Stops[1].Supercharge)
+		assert.InDelta(t, 2*JumpTime+8*TunnelTimePerLY, cons.Duration(path.Stops), 0.001)
+	}
+}
+
+func TestRouteModes(t *testing.T) {
+	graph := InitGraph(1000)
+	graph.Add(&SpaceSystem{ID: 1, Name: "Origin", X: 0, Y: 0, Z: 0})
+	graph.Add(&SpaceSystem{ID: 2, Name: "Tempting Site", X: 5, Y: 3, Z: 0})
+	graph.Add(&SpaceSystem{ID: 3, Name: "First Step", X: 4, Y: 0, Z: 0})
+	graph.Add(&SpaceSystem{ID: 4, Name: "Second Step", X: 8, Y: 0, Z: 0})
+	graph.Add(&SpaceSystem{ID: 5, Name: "Destination", X: 12, Y: 0, Z: 0})
+
+	cons := &RoutingConstraints{MaxHops: 10, MaxJump: 6, Greedy: true}
+
+	// Greedy search heads for whatever is closest to the destination, so it takes the long way
+	// through the tempting site.
+	greedy, _ := graph.FindPath(context.Background(), graph.Get(1), graph.Get(5), cons)
+	if assert.NotNil(t, greedy, "no greedy path found") {
+		assert.Equal(t, SearchGreedy, greedy.Mode)
+		assert.Equal(t, SystemID(2), greedy.Stops[1].System.ID)
+	}
+
+	cons.Greedy = false
+	optimal, _ := graph.FindPath(context.Background(), graph.Get(1), graph.Get(5), cons)
+	if assert.NotNil(t, optimal, "no optimal path found") {
+		assert.Equal(t, SearchOptimal, optimal.Mode)
+		assert.Equal(t, SystemID(3), optimal.Stops[1].System.ID, "should go straight to the destination")
+		assert.InDelta(t, 12.0, optimal.Distance, 0.0001)
+		assert.True(t, optimal.Distance < greedy.Distance)
+	}
+
+	cons.Weight = 1.5
+	weighted, _ := graph.FindPath(context.Background(), graph.Get(1), graph.Get(5), cons)
+	if assert.NotNil(t, weighted, "no weighted path found") {
+		assert.Equal(t, SearchWeighted, weighted.Mode)
+		assert.True(t, weighted.Distance <= optimal.Distance*cons.Weight)
+	}
+}
+
+func TestRouteSuperchargeDetour(t *testing.T) {
+	graph := InitGraph(1000)
+	graph.Add(&SpaceSystem{ID: 1, Name: "Origin", X: 0, Y: 0, Z: 0})
+	graph.Add(&SpaceSystem{ID: 2, Name: "Neutron Site", X: 0, Y: 4, Z: 0, ArrivalNeutronStar: true})
+	graph.Add(&SpaceSystem{ID: 3, Name: "First Step", X: 4, Y: 0, Z: 0})
+	graph.Add(&SpaceSystem{ID: 4, Name: "Second Step", X: 8, Y: 0, Z: 0})
+	graph.Add(&SpaceSystem{ID: 5, Name: "Third Step", X: 12, Y: 0, Z: 0})
+	graph.Add(&SpaceSystem{ID: 6, Name: "Destination", X: 16, Y: 0, Z: 0})
+
+	// The neutron star is further away from the destination, but supercharging there saves jumps.
+	path, _ := graph.FindPath(context.Background(), graph.Get(1), graph.Get(6), &RoutingConstraints{MaxHops: 10, MaxJump: 5, Supercharge: true})
+	if assert.NotNil(t, path, "no supercharged path found") {
+		assert.Equal(t, 3, len(path.Stops), "should detour through the neutron star")
+		assert.Equal(t, SystemID(2), path.Stops[1].System.ID)
+	}
+}
+
+func TestRouteBudget(t *testing.T) {
+	graph := InitGraph(1000).LoadSample()
+	cons := &RoutingConstraints{MaxHops: 5, MaxJump: 5, MaxExpansions: 1}
+
+	_, err := graph.FindPath(context.Background(), graph.Get(1), graph.Get(4), cons)
+	assert.True(t, errors.Is(err, ErrBudgetExhausted), "search should run out of budget")
+
+	cons.MaxExpansions = 0
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = graph.FindPath(cancelled, graph.Get(1), graph.Get(4), cons)
+	assert.True(t, errors.Is(err, context.Canceled), "search should stop when cancelled")
+
+	path, err := graph.FindPath(context.Background(), graph.Get(1), graph.Get(4), cons)
+	assert.Nil(t, err)
+	assert.NotNil(t, path)
+}
+
+func TestRouteErrors(t *testing.T) {
+	graph := InitGraph(1000).LoadSample()
+	cons := &RoutingConstraints{MaxHops: 5, MaxJump: 5}
+
+	_, err := graph.FindPath(context.Background(), graph.Get(1), graph.Get(100), cons)
+	assert.True(t, errors.Is(err, ErrUnknownSystem))
+
+	_, err = graph.FindPath(context.Background(), graph.Get(1), &SpaceSystem{ID: 100, X: 1000}, cons)
+	assert.True(t, errors.Is(err, ErrOutOfBounds))
+	assert.Equal(t, "out_of_bounds", ErrorCode(err))
+
+	_, err = graph.FindPath(context.Background(), graph.Get(1), graph.Get(4), &RoutingConstraints{MaxHops: 5, MaxJump: 1})
+	assert.True(t, errors.Is(err, ErrUnreachable))
+
+	var leg *LegError
+	if assert.True(t, errors.As(err, &leg)) {
+		assert.Equal(t, SystemID(1), leg.From)
+		assert.Equal(t, SystemID(4), leg.To)
+	}
+
+	_, err = graph.FindPath(context.Background(), graph.Get(1), graph.Get(4), &RoutingConstraints{MaxHops: 1, MaxJump: 5})
+	assert.True(t, errors.Is(err, ErrHopLimit))
+	assert.Equal(t, "hop_limit", ErrorCode(err))
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = graph.FindPath(cancelled, graph.Get(1), graph.Get(4), cons)
+	assert.Equal(t, CodeCancelled, ErrorCode(err))
+	assert.Equal(t, CodeInternal, ErrorCode(errors.New("something else")))
+}
+
+func TestRouteAvoid(t *testing.T) {
+	graph := chokepointGraph()
+
+	_, ids, _ := routeIDs(t, graph, 1, 5, &RoutingConstraints{MaxHops: 10, MaxJump: 5.5})
+	assert.Equal(t, []SystemID{1, 2, 4, 5}, ids)
+
+	_, ids, _ = routeIDs(t, graph, 1, 5, &RoutingConstraints{MaxHops: 10, MaxJump: 5.5, AvoidSystems: map[SystemID]bool{2: true}})
+	assert.Equal(t, []SystemID{1, 3, 4, 5}, ids, "should go around avoided systems")
+
+	// The destination is always allowed.
+	_, ids, _ = routeIDs(t, graph, 1, 5, &RoutingConstraints{MaxHops: 10, MaxJump: 5.5, AvoidSystems: map[SystemID]bool{5: true}})
+	assert.Equal(t, []SystemID{1, 2, 4, 5}, ids)
+
+	// The search itself only says there's no route; avoids get the blame separately.
+	_, _, err := routeIDs(t, graph, 1, 5, &RoutingConstraints{MaxHops: 10, MaxJump: 5.5, AvoidRegions: []AvoidRegion{{X: 4, Y: 1.5, Radius: 2}}})
+	assert.True(t, errors.Is(err, ErrUnreachable))
+
+	err = routeError(t, graph, 1, 5, &RoutingConstraints{MaxHops: 10, MaxJump: 5.5, AvoidRegions: []AvoidRegion{{X: 4, Y: 1.5, Radius: 2}}})
+	assert.True(t, errors.Is(err, ErrAvoided))
+
+	err = routeError(t, graph, 1, 5, &RoutingConstraints{MaxHops: 10, MaxJump: 5.5, AvoidRegions: []AvoidRegion{{X: 8, Radius: 1}}})
+	assert.Equal(t, "avoided", ErrorCode(err))
+
+	// Avoiding systems doesn't get the blame if there wasn't a route anyway.
+	err = routeError(t, graph, 1, 5, &RoutingConstraints{MaxHops: 2, MaxJump: 5.5, AvoidSystems: map[SystemID]bool{2: true}})
+	assert.True(t, errors.Is(err, ErrHopLimit))
+}
+
+func TestRoutePermits(t *testing.T) {
+	graph := chokepointGraph()
+	graph.Get(2).NeedsPermit = true
+	graph.Get(4).NeedsPermit = true
+
+	_, ids, _ := routeIDs(t, graph, 1, 4, &RoutingConstraints{MaxHops: 10, MaxJump: 5.5, Permits: map[SystemID]bool{4: true}})
+	assert.Equal(t, []SystemID{1, 3, 4}, ids, "should go around systems without a permit")
+
+	_, ids, _ = routeIDs(t, graph, 1, 4, &RoutingConstraints{MaxHops: 10, MaxJump: 5.5, Permits: map[SystemID]bool{2: true, 4: true}})
+	assert.Equal(t, []SystemID{1, 2, 4}, ids)
+
+	// Destinations need a permit too, but origins don't.
+	err := routeError(t, graph, 1, 4, &RoutingConstraints{MaxHops: 10, MaxJump: 5.5})
+	assert.True(t, errors.Is(err, ErrPermitRequired))
+
+	_, ids, _ = routeIDs(t, graph, 4, 1, &RoutingConstraints{MaxHops: 10, MaxJump: 5.5})
+	assert.Equal(t, []SystemID{4, 3, 1}, ids)
+
+	err = routeError(t, graph, 1, 5, &RoutingConstraints{MaxHops: 10, MaxJump: 5.5})
+	assert.Equal(t, "permit_required", ErrorCode(err))
+
+	_, ids, _ = routeIDs(t, graph, 1, 5, &RoutingConstraints{MaxHops: 10, MaxJump: 5.5, IgnorePermits: true})
+	assert.Equal(t, []SystemID{1, 2, 4, 5}, ids)
+
+	err = routeError(t, graph, 1, 4, &RoutingConstraints{MaxHops: 10, MaxJump: 5.5, AvoidSystems: map[SystemID]bool{3: true}, Permits: map[SystemID]bool{4: true}})
+	assert.True(t, errors.Is(err, ErrAvoided))
+
+	// If avoiding systems and missing permits are both to blame, the permit gets reported.
+	err = routeError(t, graph, 1, 5, &RoutingConstraints{MaxHops: 10, MaxJump: 5.5, AvoidSystems: map[SystemID]bool{3: true}})
+	assert.True(t, errors.Is(err, ErrPermitRequired))
+}
+
+func TestRoutePenalties(t *testing.T) {
+	graph := chokepointGraph()
+	graph.Get(2).Security = "Anarchy"
+	graph.Get(4).States = []string{"War"}
+
+	// The detour is 2 LY longer, so it's only worth taking if the penalty is bigger than that.
+	path, ids, _ := routeIDs(t, graph, 1, 5, &RoutingConstraints{MaxHops: 10, MaxJump: 5.5, Penalties: map[string]float64{"anarchy": 1}})
+	assert.Equal(t, []SystemID{1, 2, 4, 5}, ids)
+	assert.InDelta(t, 13, path.Cost, 0.0001)
+
+	path, ids, _ = routeIDs(t, graph, 1, 5, &RoutingConstraints{MaxHops: 10, MaxJump: 5.5, Penalties: map[string]float64{"anarchy": 5}})
+	assert.Equal(t, []SystemID{1, 3, 4, 5}, ids)
+	assert.InDelta(t, 14, path.Cost, 0.0001)
+
+	// There's no way around the war zone, so it's used despite the penalty.
+	path, ids, _ = routeIDs(t, graph, 1, 5, &RoutingConstraints{MaxHops: 10, MaxJump: 5.5, Penalties: map[string]float64{"war": 100}})
+	assert.Equal(t, []SystemID{1, 2, 4, 5}, ids)
+	assert.InDelta(t, 112, path.Cost, 0.0001)
+
+	_, ids, _ = routeIDs(t, graph, 1, 5, &RoutingConstraints{MaxHops: 10, MaxJump: 5.5, AvoidTypes: []string{"anarchy"}})
+	assert.Equal(t, []SystemID{1, 3, 4, 5}, ids)
+
+	err := routeError(t, graph, 1, 5, &RoutingConstraints{MaxHops: 10, MaxJump: 5.5, AvoidTypes: []string{"war"}})
+	assert.True(t, errors.Is(err, ErrAvoided))
+}
+
+func TestRouteHazards(t *testing.T) {
+	graph := InitGraph(1000)
+	graph.Add(&SpaceSystem{ID: 1, Name: "Origin", X: 0, Y: 0, Z: 0})
+	graph.Add(&SpaceSystem{ID: 2, Name: "Pulsar", X: 4, Y: 0, Z: 0, ArrivalStarClass: "N", ArrivalNeutronStar: true})
+	graph.Add(&SpaceSystem{ID: 3, Name: "Singularity", X: 4, Y: 2, Z: 0, ArrivalStarClass: "H", ArrivalBlackHole: true})
+	graph.Add(&SpaceSystem{ID: 4, Name: "Detour", X: 4, Y: -3, Z: 0, ArrivalStarClass: "K"})
+	graph.Add(&SpaceSystem{ID: 5, Name: "Chokepoint", X: 8, Y: 0, Z: 0})
+	graph.Add(&SpaceSystem{ID: 6, Name: "Destination", X: 12, Y: 0, Z: 0, ArrivalStarClass: "DA", ArrivalWhiteDwarf: true})
+
+	path, ids, _ := routeIDs(t, graph, 1, 6, &RoutingConstraints{MaxHops: 10, MaxJump: 5.5})
+	assert.Equal(t, []SystemID{1, 2, 5, 6}, ids)
+	assert.Equal(t, []string{"", HazardNeutronStar, "", HazardWhiteDwarf}, []string{
+		path.Stops[0].Hazard, path.Stops[1].Hazard, path.Stops[2].Hazard, path.Stops[3].Hazard,
+	})
+
+	// The destination is still allowed, even though it's hazardous.
+	_, ids, _ = routeIDs(t, graph, 1, 6, &RoutingConstraints{MaxHops: 10, MaxJump: 5.5, AvoidHazards: true})
+	assert.Equal(t, []SystemID{1, 4, 5, 6}, ids, "should go around hazardous systems")
+
+	err := routeError(t, graph, 1, 6, &RoutingConstraints{MaxHops: 10, MaxJump: 5.5, AvoidHazards: true, AvoidSystems: map[SystemID]bool{4: true}})
+	assert.True(t, errors.Is(err, ErrAvoided))
+
+	// Supercharged routes need neutron stars, so only black holes are avoided.
+	supercharged := &RoutingConstraints{AvoidHazards: true, Supercharge: true}
+	assert.False(t, supercharged.Avoids(graph.Get(2)))
+	assert.True(t, supercharged.Avoids(graph.Get(3)))
+	assert.Equal(t, HazardBlackHole, graph.Get(3).ArrivalHazard())
+}
+
+func TestRouteTime(t *testing.T) {
+	graph := InitGraph(1000)
+	graph.Add(&SpaceSystem{ID: 1, Name: "Origin", X: 0, Y: 0, Z: 0, ContainsScoopableStar: true})
+	graph.Add(&SpaceSystem{ID: 2, Name: "Short Hop", X: 7, Y: 0, Z: 0})
+	graph.Add(&SpaceSystem{ID: 3, Name: "Another Short Hop", X: 14, Y: 0, Z: 0})
+	graph.Add(&SpaceSystem{ID: 4, Name: "Long Hop", X: 10, Y: 4, Z: 0})
+	graph.Add(&SpaceSystem{ID: 5, Name: "Destination", X: 20, Y: 0, Z: 0})
+
+	// The shortest route takes an extra jump, which takes longer than the extra distance.
+	shortest, ids, _ := routeIDs(t, graph, 1, 5, &RoutingConstraints{MaxHops: 10, MaxJump: 11})
+	assert.Equal(t, []SystemID{1, 2, 3, 5}, ids)
+	assert.InDelta(t, 3*JumpTime+20*TunnelTimePerLY, shortest.Duration, 0.001)
+
+	quickest, ids, _ := routeIDs(t, graph, 1, 5, &RoutingConstraints{MaxHops: 10, MaxJump: 11, MinimizeTime: true})
+	assert.Equal(t, []SystemID{1, 4, 5}, ids)
+	assert.InDelta(t, 2*JumpTime+quickest.Distance*TunnelTimePerLY, quickest.Duration, 0.001)
+	assert.InDelta(t, quickest.Duration, quickest.Cost, 0.001)
+	assert.True(t, quickest.Duration < shortest.Duration)
+
+	// Topping up the tank at the origin takes (10 - 2) / 0.5 seconds.
+	scooping, _, _ := routeIDs(t, graph, 1, 5, &RoutingConstraints{MaxHops: 10, MaxJump: 11, MinimizeTime: true, TankSize: 10, FuelPerJump: 4, StartFuel: tons(2), ScoopRate: 0.5})
+	assert.InDelta(t, quickest.Duration+16, scooping.Duration, 0.001)
+	assert.InDelta(t, scooping.Duration, scooping.Cost, 0.001)
+
+	// There's no need to stop and scoop if there's already enough fuel to get there.
+	full, _, _ := routeIDs(t, graph, 1, 5, &RoutingConstraints{MaxHops: 10, MaxJump: 11, MinimizeTime: true, TankSize: 32, FuelPerJump: 1, StartFuel: tons(31), ScoopRate: 0.5})
+	assert.False(t, full.Stops[0].Refuel)
+	assert.InDelta(t, quickest.Duration, full.Duration, 0.001)
+	assert.InDelta(t, quickest.Cost, full.Cost, 0.001)
+
+	// Supercharging takes time too.
+	cons := &RoutingConstraints{MinimizeTime: true, Supercharge: true}
+	assert.InDelta(t, JumpTime+SuperchargeTime+TunnelTimePerLY*5, cons.JumpSeconds(&SpaceSystem{ArrivalNeutronStar: true}, &SpaceSystem{X: 5}), 0.001)
+	assert.Equal(t, 90.0, cons.TimeCost(90))
+}
+
+func TestRouteCustomRules(t *testing.T) {
+	graph := chokepointGraph()
+	graph.Get(3).ContainsRefuelStation = true
+	graph.Get(4).ContainsRefuelStation = true
+
+	standard, ids, _ := routeIDs(t, graph, 1, 5, &RoutingConstraints{MaxHops: 10, MaxJump: 5.5})
+	assert.Equal(t, []SystemID{1, 2, 4, 5}, ids)
+
+	// Penalise systems without stations.
+	cons := &RoutingConstraints{MaxHops: 10, MaxJump: 5.5}
+	cons.Cost = EdgeCostFunc(func(from *SpaceSystem, to *SpaceSystem) float64 {
+		if !to.ContainsRefuelStation {
+			return cons.JumpCost(from, to) + 10
+		}
+
+		return cons.JumpCost(from, to)
+	})
+
+	path, ids, _ := routeIDs(t, graph, 1, 5, cons)
+	assert.Equal(t, []SystemID{1, 3, 4, 5}, ids)
+	assert.InDelta(t, path.Distance+10, path.Cost, 0.001)
+
+	// Only jump to populated systems (the destination is always allowed).
+	populated := EdgeFilterFunc(func(from *SpaceSystem, to *SpaceSystem) bool { return to.ContainsRefuelStation })
+	_, ids, _ = routeIDs(t, graph, 1, 5, &RoutingConstraints{MaxHops: 10, MaxJump: 5.5, Filter: populated})
+	assert.Equal(t, []SystemID{1, 3, 4, 5}, ids)
+
+	// Custom filters get the blame if they're the reason there's no route.
+	err := routeError(t, graph, 1, 5, &RoutingConstraints{MaxHops: 10, MaxJump: 5.5, Filter: EdgeFilterFunc(func(from *SpaceSystem, to *SpaceSystem) bool { return to.ID != 4 })})
+	assert.True(t, errors.Is(err, ErrFiltered))
+
+	// Custom filters only add to the standard rules, so avoids and permits still count.
+	err = routeError(t, graph, 1, 5, &RoutingConstraints{MaxHops: 10, MaxJump: 5.5, Filter: populated, AvoidSystems: map[SystemID]bool{3: true}})
+	assert.True(t, errors.Is(err, ErrAvoided))
+	assert.False(t, (&RoutingConstraints{Filter: populated}).EdgeFilter().CanJump(graph.Get(1), &SpaceSystem{NeedsPermit: true, ContainsRefuelStation: true}))
+
+	// Jump range and the hop limit can be replaced too, like for a boost that's only available
+	// on the way out of the origin.
+	long := JumpReachFunc(func(from *SpaceSystem, fuel float64) float64 {
+		if from.ID == 1 {
+			return 8.5
+		}
+
+		return 5.5
+	})
+	few := HopLimitFunc(func(hops int) bool { return hops <= 2 })
+	_, ids, _ = routeIDs(t, graph, 1, 5, &RoutingConstraints{MaxHops: 10, MaxJump: 5.5, Range: long, Hops: few})
+	assert.Equal(t, []SystemID{1, 4, 5}, ids)
+
+	_, _, err = routeIDs(t, graph, 1, 5, &RoutingConstraints{MaxHops: 10, MaxJump: 5.5, Hops: few})
+	assert.True(t, errors.Is(err, ErrHopLimit))
+
+	// Without a heuristic the search is a lot less focused, but finds the same route.
+	path, ids, _ = routeIDs(t, graph, 1, 5, &RoutingConstraints{MaxHops: 10, MaxJump: 5.5, Heuristic: HeuristicFunc(func(from *SpaceSystem, to *SpaceSystem) float64 { return 0 })})
+	assert.Equal(t, []SystemID{1, 2, 4, 5}, ids)
+	assert.InDelta(t, standard.Cost, path.Cost, 0.001)
+
+	// The standard rules are used for anything that isn't replaced.
+	_, standardCost := cons.EdgeCost().(*RoutingConstraints)
+	assert.False(t, standardCost)
+	assert.Equal(t, cons, cons.EdgeFilter())
+	assert.Equal(t, cons, cons.JumpReach())
+	assert.Equal(t, cons, cons.HopLimit())
+	assert.Equal(t, cons, cons.Estimator())
+}
+
+func TestProximityMatchesBruteForce(t *testing.T) {
+	rand.Seed(3)
+
+	for _, cell := range []float64{50, 300, 1000, 2500, 7000} {
+		graph := InitGraph(cell)
+		systems := make([]*SpaceSystem, 0)
+
+		// Spread systems across several cells so that plenty of them are near cell edges.
+		for i := 0; i < 2000; i++ {
+			system := &SpaceSystem{
+				ID: SystemID(i + 1),
+				X:  rand.Float64()*6000 - 3000,
+				Y:  rand.Float64()*6000 - 3000,
+				Z:  rand.Float64()*6000 - 3000,
+			}
+
+			graph.Add(system)
+			systems = append(systems, system)
+		}
+
+		for _, radius := range []float64{50, 400, 1200, 3000} {
+			for i := 0; i < 20; i++ {
+				origin := systems[rand.Intn(len(systems))]
+
+				expected := make(map[SystemID]bool)
+				for _, system := range systems {
+					if origin.DistanceTo(system) < radius {
+						expected[system.ID] = true
+					}
+				}
+
+				found := make(map[SystemID]bool)
+				for _, system := range graph.Proximity(origin, radius) {
+					assert.False(t, found[system.ID], "system returned more than once")
+					found[system.ID] = true
+				}
+
+				assert.Equal(t, expected, found, "cell %v, radius %v", cell, radius)
+			}
+		}
+	}
+}
+
+func TestNearbyBucketsAtEdge(t *testing.T) {
+	graph := InitGraph(50)
+	graph.Add(&SpaceSystem{ID: 1, X: -200, Y: 0, Z: 0})
+	graph.Add(&SpaceSystem{ID: 2, X: 200, Y: 120, Z: 0})
+	graph.Add(&SpaceSystem{ID: 3, X: 0, Y: 0, Z: 49.5})
+	graph.Add(&SpaceSystem{ID: 4, X: 0, Y: 0, Z: 50.5})
+
+	assert.Equal(t, 4, graph.BucketCount(), "only occupied buckets should be stored")
+
+	// Searching well beyond the edge of the known systems should find everything, once.
+	buckets := graph.NearbyBuckets(&SpaceSystem{X: -500, Y: 0, Z: 0}, 10000)
+	seen := make(map[*SpaceBucket]bool)
+
+	for _, bucket := range buckets {
+		assert.False(t, seen[bucket], "bucket returned more than once")
+		seen[bucket] = true
+	}
+
+	assert.Equal(t, 4, len(buckets))
+	assert.Equal(t, 0, len(graph.NearbyBuckets(&SpaceSystem{X: 5000, Y: 0, Z: 0}, 100)))
+}
+
+func TestGraphBounds(t *testing.T) {
+	graph := InitGraph(1000).LoadSample()
+
+	assert.True(t, graph.InBounds(graph.Get(1)))
+	assert.True(t, graph.InBounds(&SpaceSystem{X: 3, Y: 3, Z: 3}))
+	assert.False(t, graph.InBounds(&SpaceSystem{X: 3, Y: 3, Z: 30}))
+}
+
+/**
+ * Finds a route between two systems in the graph, and lists the systems it passes through.
+ */
+func routeIDs(t *testing.T, graph *SpaceGraph, from SystemID, to SystemID, cons *RoutingConstraints) (*SpaceRoute, []SystemID, error) {
+	t.Helper()
+
+	path, err := graph.FindPath(context.Background(), graph.Get(from), graph.Get(to), cons)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ids := make([]SystemID, len(path.Stops))
+	for i, stop := range path.Stops {
+		ids[i] = stop.System.ID
+	}
+
+	return path, ids, nil
+}
+
+func tons(fuel float64) *float64 {
+	return &fuel
+}
+
+/**
+ * Finds out why there isn't a route between two systems, including whether avoids or permits
+ * are to blame.
+ */
+func routeError(t *testing.T, graph *SpaceGraph, from SystemID, to SystemID, cons *RoutingConstraints) error {
+	t.Helper()
+
+	_, _, err := routeIDs(t, graph, from, to, cons)
+
+	return graph.BlameRestrictions(context.Background(), err, cons)
+}
@@ -0,0 +1,158 @@
+package structs
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+)
+
+/**
+ * Ship describes the parts of a ship's loadout that affect how far it can jump. Masses are
+ * in tons. The jump range of a ship changes throughout a route as fuel is burned, so ranges
+ * are always calculated for a specific amount of fuel in the tank.
+ */
+type Ship struct {
+	Name           string
+	HullMass       float64 // unladen mass, including all modules
+	FSDClass       int     // 2 - 7
+	FSDRating      string  // A - E
+	OptimalMass    float64 // optimal mass of the frame shift drive
+	MaxFuelPerJump float64 // most fuel the frame shift drive can use in a single jump
+	FuelCapacity   float64 // size of the fuel tank
+	CargoMass      float64
+	Booster        int    // class of the guardian FSD booster, zero if there isn't one
+	PadSize        string // smallest landing pad the ship fits on: "S", "M" or "L"
+}
+
+// Linear constant for each FSD rating, used to determine fuel cost.
+var fsdRatingConstants = map[string]float64{
+	"A": 12.0,
+	"B": 10.0,
+	"C": 8.0,
+	"D": 10.0,
+	"E": 11.0,
+}
+
+// Power constant for each FSD class, used to determine fuel cost.
+var fsdClassConstants = map[int]float64{
+	2: 2.00,
+	3: 2.15,
+	4: 2.30,
+	5: 2.45,
+	6: 2.60,
+	7: 2.75,
+}
+
+// Additional range (in LY) provided by each class of guardian FSD booster.
+var boosterRanges = map[int]float64{
+	1: 4.00,
+	2: 6.00,
+	3: 7.75,
+	4: 9.25,
+	5: 10.50,
+}
+
+/**
+ * A handful of common loadouts that can be requested by name.
+ */
+var Ships = map[string]Ship{
+	"sidewinder": {Name: "Sidewinder", HullMass: 47, FSDClass: 2, FSDRating: "A", OptimalMass: 90, MaxFuelPerJump: 0.9, FuelCapacity: 2, PadSize: "S"},
+	"asp":        {Name: "Asp Explorer", HullMass: 280, FSDClass: 5, FSDRating: "A", OptimalMass: 1050, MaxFuelPerJump: 5, FuelCapacity: 32, PadSize: "M"},
+	"python":     {Name: "Python", HullMass: 350, FSDClass: 5, FSDRating: "A", OptimalMass: 1050, MaxFuelPerJump: 5, FuelCapacity: 32, PadSize: "M"},
+	"anaconda":   {Name: "Anaconda", HullMass: 400, FSDClass: 6, FSDRating: "A", OptimalMass: 1800, MaxFuelPerJump: 8, FuelCapacity: 32, PadSize: "L"},
+}
+
+/**
+ * Parses an FSD descriptor like "5A" into its class and rating.
+ */
+func ParseFSD(fsd string) (int, string, error) {
+	fsd = strings.ToUpper(strings.TrimSpace(fsd))
+	if len(fsd) < 2 {
+		return 0, "", errors.New("FSD should be a class followed by a rating, like 5A")
+	}
+
+	class, err := strconv.Atoi(fsd[:len(fsd)-1])
+	rating := fsd[len(fsd)-1:]
+
+	if _, exists := fsdClassConstants[class]; err != nil || !exists {
+		return 0, "", errors.New("unknown FSD class in " + fsd)
+	}
+
+	if _, exists := fsdRatingConstants[rating]; !exists {
+		return 0, "", errors.New("unknown FSD rating in " + fsd)
+	}
+
+	return class, rating, nil
+}
+
+/**
+ * Total mass of the ship with the specified amount of fuel in the tank.
+ */
+func (ship *Ship) Mass(fuel float64) float64 {
+	return ship.HullMass + ship.CargoMass + fuel
+}
+
+/**
+ * Range of the ship's FSD when jumping with the specified amount of fuel, ignoring the booster.
+ */
+func (ship *Ship) baseRange(mass float64, fuel float64) float64 {
+	linear := fsdRatingConstants[ship.FSDRating]
+	power := fsdClassConstants[ship.FSDClass]
+
+	if linear == 0 || power == 0 || mass <= 0 {
+		return 0
+	}
+
+	return (ship.OptimalMass / mass) * math.Pow(1000*fuel/linear, 1/power)
+}
+
+/**
+ * The guardian booster adds a fixed amount of range at optimal mass. It's applied here as a
+ * multiplier on the base range so that it also reduces the fuel cost of shorter jumps.
+ */
+func (ship *Ship) boostFactor() float64 {
+	boost, exists := boosterRanges[ship.Booster]
+	if !exists {
+		return 1
+	}
+
+	base := ship.baseRange(ship.OptimalMass, ship.MaxFuelPerJump)
+	if base == 0 {
+		return 1
+	}
+
+	return (base + boost) / base
+}
+
+/**
+ * Returns the longest jump (in LY) the ship can make with the specified amount of fuel in the
+ * tank. The tank can't supply more than MaxFuelPerJump in one go, and ships without a tank
+ * capacity are assumed to always be able to.
+ */
+func (ship *Ship) JumpRange(fuel float64) float64 {
+	usable := ship.MaxFuelPerJump
+	if ship.FuelCapacity > 0 {
+		usable = math.Min(usable, fuel)
+	} else {
+		fuel = 0
+	}
+
+	return ship.baseRange(ship.Mass(fuel), usable) * ship.boostFactor()
+}
+
+/**
+ * Returns the amount of fuel used to jump the specified distance with the specified amount of
+ * fuel in the tank. This is the inverse of JumpRange().
+ */
+func (ship *Ship) FuelUse(distance float64, fuel float64) float64 {
+	linear := fsdRatingConstants[ship.FSDRating]
+	power := fsdClassConstants[ship.FSDClass]
+
+	if ship.OptimalMass == 0 {
+		return 0
+	}
+
+	base := distance / ship.boostFactor()
+	return linear * 0.001 * math.Pow(base*ship.Mass(fuel)/ship.OptimalMass, power)
+}
@@ -0,0 +1,60 @@
+package structs
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShipRange(t *testing.T) {
+	ship := Ships["anaconda"]
+	full := ship.JumpRange(ship.FuelCapacity)
+	low := ship.JumpRange(ship.MaxFuelPerJump)
+
+	assert.InDelta(t, 50.8, full, 0.1, "unexpected range on a full tank")
+	assert.True(t, low > full, "burning fuel should increase range")
+	assert.True(t, ship.JumpRange(ship.MaxFuelPerJump/2) < low, "range should be limited by fuel in the tank")
+
+	ship.CargoMass = 200
+	assert.True(t, ship.JumpRange(ship.FuelCapacity) < full, "cargo should reduce range")
+}
+
+func TestShipFuelUse(t *testing.T) {
+	ship := Ships["asp"]
+	ship.Booster = 5
+
+	// A maximum range jump should use exactly the FSD's max fuel per jump.
+	jump := ship.JumpRange(ship.FuelCapacity)
+	assert.InDelta(t, ship.MaxFuelPerJump, ship.FuelUse(jump, ship.FuelCapacity), 0.0001)
+	assert.True(t, ship.FuelUse(jump/2, ship.FuelCapacity) < ship.MaxFuelPerJump/2, "fuel use should be sublinear")
+}
+
+func TestParseFSD(t *testing.T) {
+	class, rating, err := ParseFSD("5a")
+	assert.Nil(t, err)
+	assert.Equal(t, 5, class)
+	assert.Equal(t, "A", rating)
+
+	_, _, err = ParseFSD("9A")
+	assert.NotNil(t, err)
+
+	_, _, err = ParseFSD("5F")
+	assert.NotNil(t, err)
+}
+
+func TestRouteWithShip(t *testing.T) {
+	ship := Ships["sidewinder"]
+	graph := InitGraph(1000)
+	graph.Add(&SpaceSystem{ID: 1, Name: "Origin", X: 0, Y: 0, Z: 0, ContainsScoopableStar: true})
+	graph.Add(&SpaceSystem{ID: 2, Name: "Close Site", X: 10, Y: 0, Z: 0, ContainsScoopableStar: true})
+	graph.Add(&SpaceSystem{ID: 3, Name: "Destination", X: 20, Y: 0, Z: 0})
+
+	// The sidewinder can't reach the destination in a single jump.
+	path, _ := graph.FindPath(context.Background(), graph.Get(1), graph.Get(3), &RoutingConstraints{MaxHops: 5, Ship: &ship})
+
+	if assert.NotNil(t, path, "no path found") {
+		assert.Equal(t, 3, len(path.Stops))
+		assert.True(t, path.Stops[2].FuelRemaining < ship.FuelCapacity)
+	}
+}
@@ -0,0 +1,226 @@
+package structs
+
+import (
+	"container/heap"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+/**
+ * SpatialIndex answers geometric queries about a set of systems. Points are passed around as
+ * SpaceSystem's, but only their coordinates are used, so they don't need to be real systems.
+ */
+type SpatialIndex interface {
+	Insert(system *SpaceSystem)
+
+	// All systems strictly within the radius of the origin, in no particular order.
+	Within(origin *SpaceSystem, radius float64) []*SpaceSystem
+	// The k systems closest to the origin, closest first.
+	Nearest(origin *SpaceSystem, k int) []*SpaceSystem
+	// All systems inside the box with corners at low and high, including its edges.
+	InBox(low *SpaceSystem, high *SpaceSystem) []*SpaceSystem
+}
+
+func inBox(system *SpaceSystem, low *SpaceSystem, high *SpaceSystem) bool {
+	return system.X >= low.X && system.X <= high.X &&
+		system.Y >= low.Y && system.Y <= high.Y &&
+		system.Z >= low.Z && system.Z <= high.Z
+}
+
+func coordinate(system *SpaceSystem, axis int) float64 {
+	switch axis {
+	case 0:
+		return system.X
+	case 1:
+		return system.Y
+	default:
+		return system.Z
+	}
+}
+
+/**
+ * KDTree is a balanced k-d tree over system coordinates. The tree is stored implicitly in a
+ * slice: the median of each range is the node for that range, everything before it is the left
+ * subtree and everything after it is the right subtree. Each level splits on the next axis.
+ *
+ * Systems can be inserted at any time, but the tree is only rebuilt the next time it's queried.
+ * That makes bulk loading cheap, but interleaving lots of inserts and queries is slow.
+ */
+type KDTree struct {
+	lock    sync.Mutex
+	systems []*SpaceSystem
+	dirty   atomic.Bool
+}
+
+func NewKDTree() *KDTree {
+	return new(KDTree)
+}
+
+func (tree *KDTree) Insert(system *SpaceSystem) {
+	tree.lock.Lock()
+	defer tree.lock.Unlock()
+
+	tree.systems = append(tree.systems, system)
+	tree.dirty.Store(true)
+}
+
+/**
+ * Rebuilds the tree if anything's been inserted since it was last built, and returns the nodes.
+ */
+func (tree *KDTree) nodes() []*SpaceSystem {
+	if tree.dirty.Load() {
+		tree.lock.Lock()
+		if tree.dirty.Load() {
+			build(tree.systems, 0)
+			tree.dirty.Store(false)
+		}
+		tree.lock.Unlock()
+	}
+
+	return tree.systems
+}
+
+func build(systems []*SpaceSystem, axis int) {
+	if len(systems) <= 1 {
+		return
+	}
+
+	sort.Slice(systems, func(i, j int) bool {
+		return coordinate(systems[i], axis) < coordinate(systems[j], axis)
+	})
+
+	mid := len(systems) / 2
+	build(systems[:mid], (axis+1)%3)
+	build(systems[mid+1:], (axis+1)%3)
+}
+
+func (tree *KDTree) Within(origin *SpaceSystem, radius float64) []*SpaceSystem {
+	var items []*SpaceSystem
+
+	var search func(nodes []*SpaceSystem, axis int)
+	search = func(nodes []*SpaceSystem, axis int) {
+		if len(nodes) == 0 {
+			return
+		}
+
+		mid := len(nodes) / 2
+		node := nodes[mid]
+		if origin.DistanceTo(node) < radius {
+			items = append(items, node)
+		}
+
+		split := coordinate(node, axis)
+		if coordinate(origin, axis)-radius <= split {
+			search(nodes[:mid], (axis+1)%3)
+		}
+		if coordinate(origin, axis)+radius >= split {
+			search(nodes[mid+1:], (axis+1)%3)
+		}
+	}
+
+	search(tree.nodes(), 0)
+
+	return items
+}
+
+func (tree *KDTree) Nearest(origin *SpaceSystem, k int) []*SpaceSystem {
+	if k <= 0 {
+		return nil
+	}
+
+	// Max-heap of the closest systems found so far, so the furthest one is easy to replace.
+	best := &nearestQueue{}
+
+	var search func(nodes []*SpaceSystem, axis int)
+	search = func(nodes []*SpaceSystem, axis int) {
+		if len(nodes) == 0 {
+			return
+		}
+
+		mid := len(nodes) / 2
+		node := nodes[mid]
+
+		if distance := origin.DistanceTo(node); best.Len() < k {
+			heap.Push(best, nearestItem{system: node, distance: distance})
+		} else if distance < best.items[0].distance {
+			best.items[0] = nearestItem{system: node, distance: distance}
+			heap.Fix(best, 0)
+		}
+
+		// Search the side the origin is on first, then the other side only if it could contain
+		// something closer than what's already been found.
+		offset := coordinate(origin, axis) - coordinate(node, axis)
+		near, far := nodes[:mid], nodes[mid+1:]
+		if offset > 0 {
+			near, far = far, near
+		}
+
+		search(near, (axis+1)%3)
+		if best.Len() < k || offset*offset < best.items[0].distance*best.items[0].distance {
+			search(far, (axis+1)%3)
+		}
+	}
+
+	search(tree.nodes(), 0)
+
+	items := make([]*SpaceSystem, best.Len())
+	for i := len(items) - 1; i >= 0; i-- {
+		items[i] = heap.Pop(best).(nearestItem).system
+	}
+
+	return items
+}
+
+func (tree *KDTree) InBox(low *SpaceSystem, high *SpaceSystem) []*SpaceSystem {
+	var items []*SpaceSystem
+
+	var search func(nodes []*SpaceSystem, axis int)
+	search = func(nodes []*SpaceSystem, axis int) {
+		if len(nodes) == 0 {
+			return
+		}
+
+		mid := len(nodes) / 2
+		node := nodes[mid]
+		if inBox(node, low, high) {
+			items = append(items, node)
+		}
+
+		split := coordinate(node, axis)
+		if coordinate(low, axis) <= split {
+			search(nodes[:mid], (axis+1)%3)
+		}
+		if coordinate(high, axis) >= split {
+			search(nodes[mid+1:], (axis+1)%3)
+		}
+	}
+
+	search(tree.nodes(), 0)
+
+	return items
+}
+
+type nearestItem struct {
+	system   *SpaceSystem
+	distance float64
+}
+
+type nearestQueue struct {
+	items []nearestItem
+}
+
+func (q *nearestQueue) Len() int           { return len(q.items) }
+func (q *nearestQueue) Less(i, j int) bool { return q.items[i].distance > q.items[j].distance }
+func (q *nearestQueue) Swap(i, j int)      { q.items[i], q.items[j] = q.items[j], q.items[i] }
+
+func (q *nearestQueue) Push(x interface{}) {
+	q.items = append(q.items, x.(nearestItem))
+}
+
+func (q *nearestQueue) Pop() interface{} {
+	last := q.items[len(q.items)-1]
+	q.items = q.items[:len(q.items)-1]
+
+	return last
+}
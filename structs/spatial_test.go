@@ -0,0 +1,135 @@
+package structs
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func randomSystems(count int, size float64) []*SpaceSystem {
+	systems := make([]*SpaceSystem, count)
+	for i := range systems {
+		systems[i] = &SpaceSystem{
+			ID: SystemID(i + 1),
+			X:  rand.Float64()*size - size/2,
+			Y:  rand.Float64()*size - size/2,
+			Z:  rand.Float64()*size - size/2,
+		}
+	}
+
+	return systems
+}
+
+func systemIDs(systems []*SpaceSystem) []SystemID {
+	ids := make([]SystemID, len(systems))
+	for i, system := range systems {
+		ids[i] = system.ID
+	}
+
+	return ids
+}
+
+func sortedIDs(systems []*SpaceSystem) []SystemID {
+	ids := systemIDs(systems)
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	return ids
+}
+
+func TestSpatialIndexes(t *testing.T) {
+	systems := randomSystems(3000, 2000)
+
+	indexes := map[string]SpatialIndex{
+		"grid":   NewSpaceGrid(100),
+		"kdtree": NewKDTree(),
+	}
+
+	for _, index := range indexes {
+		for _, system := range systems {
+			index.Insert(system)
+		}
+	}
+
+	for name, index := range indexes {
+		for i := 0; i < 20; i++ {
+			// Origins are arbitrary points, not systems.
+			origin := &SpaceSystem{X: rand.Float64()*2400 - 1200, Y: rand.Float64()*2400 - 1200, Z: rand.Float64()*2400 - 1200}
+
+			var within []*SpaceSystem
+			for _, system := range systems {
+				if origin.DistanceTo(system) < 250 {
+					within = append(within, system)
+				}
+			}
+			assert.Equal(t, sortedIDs(within), sortedIDs(index.Within(origin, 250)), "%s within", name)
+
+			byDistance := append([]*SpaceSystem{}, systems...)
+			sort.Slice(byDistance, func(i, j int) bool {
+				return origin.DistanceTo(byDistance[i]) < origin.DistanceTo(byDistance[j])
+			})
+			assert.Equal(t, systemIDs(byDistance[:1]), systemIDs(index.Nearest(origin, 1)), "%s nearest", name)
+			assert.Equal(t, systemIDs(byDistance[:25]), systemIDs(index.Nearest(origin, 25)), "%s 25 nearest", name)
+
+			low := &SpaceSystem{X: origin.X - 150, Y: origin.Y - 300, Z: origin.Z - 50}
+			high := &SpaceSystem{X: origin.X + 150, Y: origin.Y + 100, Z: origin.Z + 200}
+
+			var boxed []*SpaceSystem
+			for _, system := range systems {
+				if inBox(system, low, high) {
+					boxed = append(boxed, system)
+				}
+			}
+			assert.Equal(t, sortedIDs(boxed), sortedIDs(index.InBox(low, high)), "%s box", name)
+		}
+
+		// Asking for more systems than there are returns all of them.
+		assert.Equal(t, len(systems), len(index.Nearest(&SpaceSystem{}, len(systems)+10)), name)
+		assert.Equal(t, 0, len(index.Nearest(&SpaceSystem{}, 0)), name)
+	}
+}
+
+func TestGraphUseIndex(t *testing.T) {
+	graph := InitGraph(1000).LoadSample()
+	graph.UseIndex(NewKDTree())
+
+	// Systems added after switching should end up in the new index too.
+	graph.Add(&SpaceSystem{ID: 7, Name: "Seventh Site", X: 2, Y: 2, Z: 2})
+
+	origin := &SpaceSystem{X: 2.4, Y: 2.4, Z: 2.4}
+	assert.Equal(t, []SystemID{6, 7}, systemIDs(graph.Nearest(origin, 2)))
+	assert.Equal(t, []SystemID{6, 7}, sortedIDs(graph.Proximity(origin, 1)))
+	assert.Equal(t, []SystemID{2, 4, 6, 7}, sortedIDs(graph.InBox(&SpaceSystem{X: -1, Y: -1, Z: -1}, &SpaceSystem{X: 3, Y: 3, Z: 3})))
+}
+
+func benchmarkWithin(b *testing.B, index SpatialIndex, radius float64) {
+	systems := randomSystems(100000, 10000)
+	for _, system := range systems {
+		index.Insert(system)
+	}
+	index.Within(systems[0], radius)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		index.Within(systems[i%len(systems)], radius)
+	}
+}
+
+func benchmarkNearest(b *testing.B, index SpatialIndex, k int) {
+	systems := randomSystems(100000, 10000)
+	for _, system := range systems {
+		index.Insert(system)
+	}
+	index.Nearest(systems[0], k)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		index.Nearest(systems[i%len(systems)], k)
+	}
+}
+
+func BenchmarkWithinGrid(b *testing.B)    { benchmarkWithin(b, NewSpaceGrid(1000), 500) }
+func BenchmarkWithinKDTree(b *testing.B)  { benchmarkWithin(b, NewKDTree(), 500) }
+func BenchmarkNearestGrid(b *testing.B)   { benchmarkNearest(b, NewSpaceGrid(1000), 10) }
+func BenchmarkNearestKDTree(b *testing.B) { benchmarkNearest(b, NewKDTree(), 10) }
@@ -0,0 +1,295 @@
+package structs
+
+import (
+	"math"
+	"time"
+)
+
+/**
+ * Largest number of stops that OrderStops will solve exactly. Held-Karp needs O(2^n * n^2) time
+ * and O(2^n * n) memory, so anything much larger than this should use the heuristics instead.
+ */
+const HeldKarpLimit = 13
+
+/**
+ * A multi-stop routing problem. Costs[i][j] is the cost of the leg from stop i to stop j, and
+ * should be +Inf if there's no way to get from one to the other. If FixedStart is set then stop
+ * 0 must be visited first, and if FixedEnd is set then the last stop must be visited last.
+ */
+type Tour struct {
+	Costs      [][]float64
+	FixedStart bool
+	FixedEnd   bool
+}
+
+/**
+ * Returns the total cost of visiting the stops in the specified order.
+ */
+func (tour *Tour) Cost(order []int) float64 {
+	total := 0.0
+	for i := 1; i < len(order); i++ {
+		total += tour.Costs[order[i-1]][order[i]]
+	}
+
+	return total
+}
+
+/**
+ * Finds the cheapest order to visit every stop in. Small tours are solved exactly with Held-Karp,
+ * and larger ones are built with a nearest neighbour tour that's then improved with 2-opt and
+ * Or-opt moves until it stops getting better or the budget runs out.
+ */
+func (tour *Tour) OrderStops(budget time.Duration) []int {
+	if len(tour.Costs) <= HeldKarpLimit {
+		return tour.heldKarp()
+	}
+
+	deadline := time.Now().Add(budget)
+	order := tour.nearestNeighbour()
+
+	for improved := true; improved && time.Now().Before(deadline); {
+		improved = tour.twoOpt(order, deadline)
+		improved = tour.orOpt(order, deadline) || improved
+	}
+
+	return order
+}
+
+/**
+ * Returns the range of positions in a tour that are allowed to move.
+ */
+func (tour *Tour) movable() (int, int) {
+	first, last := 0, len(tour.Costs)-1
+
+	if tour.FixedStart {
+		first++
+	}
+
+	if tour.FixedEnd {
+		last--
+	}
+
+	return first, last
+}
+
+/**
+ * Exact solution using the Held-Karp dynamic program. best[set][j] is the cost of the cheapest
+ * path that visits exactly the stops in `set` and ends at stop j.
+ */
+func (tour *Tour) heldKarp() []int {
+	n := len(tour.Costs)
+	if n == 0 {
+		return []int{}
+	}
+
+	full := 1<<uint(n) - 1
+	best := make([][]float64, full+1)
+	prev := make([][]int, full+1)
+
+	for set := range best {
+		best[set] = make([]float64, n)
+		prev[set] = make([]int, n)
+
+		for j := range best[set] {
+			best[set][j] = math.Inf(1)
+			prev[set][j] = -1
+		}
+	}
+
+	// Paths can start at any stop unless the start is fixed. The fixed end can only start a path
+	// if it's the only stop.
+	for j := 0; j < n; j++ {
+		if tour.FixedStart && j != 0 {
+			continue
+		}
+
+		if tour.FixedEnd && j == n-1 && n > 1 {
+			continue
+		}
+
+		best[1<<uint(j)][j] = 0
+	}
+
+	for set := 1; set <= full; set++ {
+		for j := 0; j < n; j++ {
+			if set&(1<<uint(j)) == 0 || math.IsInf(best[set][j], 1) {
+				continue
+			}
+
+			for k := 0; k < n; k++ {
+				if set&(1<<uint(k)) != 0 {
+					continue
+				}
+
+				// The fixed end can only be added once everything else has been visited.
+				next := set | 1<<uint(k)
+				if tour.FixedEnd && k == n-1 && next != full {
+					continue
+				}
+
+				if cost := best[set][j] + tour.Costs[j][k]; cost < best[next][k] {
+					best[next][k] = cost
+					prev[next][k] = j
+				}
+			}
+		}
+	}
+
+	last := n - 1
+	if !tour.FixedEnd {
+		for j := 0; j < n; j++ {
+			if best[full][j] < best[full][last] {
+				last = j
+			}
+		}
+	}
+
+	// Walk backwards from the last stop to recover the order.
+	order := make([]int, n)
+	for set, j, i := full, last, n-1; i >= 0; i-- {
+		order[i] = j
+		j, set = prev[set][j], set&^(1<<uint(j))
+
+		// No complete path exists (some legs are unreachable); fall back to the requested order.
+		if j < 0 && i > 0 {
+			return tour.identity()
+		}
+	}
+
+	return order
+}
+
+func (tour *Tour) identity() []int {
+	order := make([]int, len(tour.Costs))
+	for i := range order {
+		order[i] = i
+	}
+
+	return order
+}
+
+/**
+ * Builds an initial tour by always moving on to the cheapest unvisited stop. If the start isn't
+ * fixed, the tour starts from the first movable stop.
+ */
+func (tour *Tour) nearestNeighbour() []int {
+	n := len(tour.Costs)
+	order := make([]int, 0, n)
+	visited := make([]bool, n)
+
+	first, last := tour.movable()
+	if tour.FixedStart {
+		order = append(order, 0)
+		visited[0] = true
+	} else {
+		order = append(order, first)
+		visited[first] = true
+	}
+
+	for len(order) < last+1 {
+		current := order[len(order)-1]
+		next := -1
+
+		for k := first; k <= last; k++ {
+			if !visited[k] && (next < 0 || tour.Costs[current][k] < tour.Costs[current][next]) {
+				next = k
+			}
+		}
+
+		order = append(order, next)
+		visited[next] = true
+	}
+
+	if tour.FixedEnd {
+		order = append(order, n-1)
+	}
+
+	return order
+}
+
+/**
+ * Reverses segments of the tour if it makes the tour cheaper. Costs aren't necessarily symmetric
+ * so every candidate is compared by the full tour cost. Returns true if anything changed.
+ */
+func (tour *Tour) twoOpt(order []int, deadline time.Time) bool {
+	first, last := tour.movable()
+	improved := false
+	cost := tour.Cost(order)
+
+	for i := first; i < last; i++ {
+		for j := i + 1; j <= last; j++ {
+			reverse(order, i, j)
+
+			if next := tour.Cost(order); next < cost {
+				cost = next
+				improved = true
+			} else {
+				reverse(order, i, j)
+			}
+		}
+
+		if time.Now().After(deadline) {
+			break
+		}
+	}
+
+	return improved
+}
+
+/**
+ * Moves segments of one to three consecutive stops to other positions in the tour if it makes
+ * the tour cheaper. Returns true if anything changed.
+ */
+func (tour *Tour) orOpt(order []int, deadline time.Time) bool {
+	first, last := tour.movable()
+	improved := false
+	cost := tour.Cost(order)
+
+	for length := 1; length <= 3; length++ {
+		for i := first; i+length-1 <= last; i++ {
+			for j := first; j+length-1 <= last; j++ {
+				if i == j {
+					continue
+				}
+
+				candidate := moveSegment(order, i, length, j)
+
+				if next := tour.Cost(candidate); next < cost {
+					copy(order, candidate)
+					cost = next
+					improved = true
+				}
+			}
+
+			if time.Now().After(deadline) {
+				return improved
+			}
+		}
+	}
+
+	return improved
+}
+
+func reverse(order []int, i int, j int) {
+	for ; i < j; i, j = i+1, j-1 {
+		order[i], order[j] = order[j], order[i]
+	}
+}
+
+/**
+ * Returns a copy of the order with the `length` stops starting at position i moved so that they
+ * start at position j instead.
+ */
+func moveSegment(order []int, i int, length int, j int) []int {
+	segment := order[i : i+length]
+	rest := make([]int, 0, len(order)-length)
+	rest = append(rest, order[:i]...)
+	rest = append(rest, order[i+length:]...)
+
+	moved := make([]int, 0, len(order))
+	moved = append(moved, rest[:j]...)
+	moved = append(moved, segment...)
+	moved = append(moved, rest[j:]...)
+
+	return moved
+}
@@ -0,0 +1,119 @@
+package structs
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func randomTour(n int, fixedStart bool, fixedEnd bool) *Tour {
+	tour := &Tour{FixedStart: fixedStart, FixedEnd: fixedEnd, Costs: make([][]float64, n)}
+	systems := make([]*SpaceSystem, n)
+
+	for i := range systems {
+		systems[i] = &SpaceSystem{X: rand.Float64() * 100, Y: rand.Float64() * 100, Z: rand.Float64() * 100}
+	}
+
+	for i := range tour.Costs {
+		tour.Costs[i] = make([]float64, n)
+		for j := range tour.Costs[i] {
+			tour.Costs[i][j] = systems[i].DistanceTo(systems[j])
+		}
+	}
+
+	return tour
+}
+
+// Cheapest order found by trying every permutation.
+func bruteForce(tour *Tour) float64 {
+	best := math.Inf(1)
+	order := tour.identity()
+
+	var permute func(int)
+	permute = func(k int) {
+		if k == len(order) {
+			if tour.FixedStart && order[0] != 0 {
+				return
+			}
+			if tour.FixedEnd && order[len(order)-1] != len(order)-1 {
+				return
+			}
+
+			best = math.Min(best, tour.Cost(order))
+			return
+		}
+
+		for i := k; i < len(order); i++ {
+			order[k], order[i] = order[i], order[k]
+			permute(k + 1)
+			order[k], order[i] = order[i], order[k]
+		}
+	}
+	permute(0)
+
+	return best
+}
+
+func assertPermutation(t *testing.T, tour *Tour, order []int) {
+	sorted := append([]int{}, order...)
+	sort.Ints(sorted)
+	assert.Equal(t, tour.identity(), sorted, "order should visit every stop once")
+
+	if tour.FixedStart {
+		assert.Equal(t, 0, order[0], "fixed start moved")
+	}
+
+	if tour.FixedEnd {
+		assert.Equal(t, len(order)-1, order[len(order)-1], "fixed end moved")
+	}
+}
+
+func TestHeldKarp(t *testing.T) {
+	rand.Seed(1)
+
+	for _, fixed := range [][]bool{{false, false}, {true, false}, {false, true}, {true, true}} {
+		for n := 2; n <= 7; n++ {
+			tour := randomTour(n, fixed[0], fixed[1])
+			order := tour.OrderStops(time.Second)
+
+			assertPermutation(t, tour, order)
+			assert.InDelta(t, bruteForce(tour), tour.Cost(order), 0.0001, "held-karp should be optimal")
+		}
+	}
+}
+
+func TestHeldKarpUnreachable(t *testing.T) {
+	inf := math.Inf(1)
+	tour := &Tour{FixedStart: true, Costs: [][]float64{
+		{0, 1, inf},
+		{1, 0, inf},
+		{inf, inf, 0},
+	}}
+
+	assert.Equal(t, []int{0, 1, 2}, tour.OrderStops(time.Second))
+}
+
+func TestTourHeuristics(t *testing.T) {
+	rand.Seed(2)
+
+	for _, fixed := range [][]bool{{false, false}, {true, true}} {
+		tour := randomTour(40, fixed[0], fixed[1])
+		initial := tour.Cost(tour.nearestNeighbour())
+		order := tour.OrderStops(time.Second)
+
+		assertPermutation(t, tour, order)
+		assert.True(t, tour.Cost(order) <= initial, "improvement shouldn't make the tour worse")
+	}
+}
+
+func BenchmarkOrderStops(b *testing.B) {
+	tour := randomTour(15, true, false)
+
+	for i := 0; i < b.N; i++ {
+		tour.OrderStops(time.Second)
+	}
+}